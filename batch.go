@@ -0,0 +1,359 @@
+package elfy
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// OperationKind names the kind of edit performed by an Operation.
+type OperationKind string
+
+const (
+	OpAdd      OperationKind = "add"       // Add a new section; fails if it exists
+	OpReplace  OperationKind = "replace"   // Replace the contents of an existing section
+	OpRemove   OperationKind = "remove"    // Remove an existing section
+	OpRename   OperationKind = "rename"    // Rename an existing section to NewName
+	OpSetFlags OperationKind = "set-flags" // Change the flags of an existing section
+)
+
+// Operation is a single step of a batch edit; see File.Apply.
+type Operation struct {
+	Kind    OperationKind
+	Name    string         // Section the operation applies to
+	NewName string         // New name, for OpRename
+	Data    []byte         // Section contents, for OpAdd and OpReplace
+	Options SectionOptions // Header attributes, for OpAdd, OpReplace, OpRename and OpSetFlags
+	Cascade bool           // Remove dependent sections too, for OpRemove
+}
+
+// String returns a short description of the operation.
+func (op Operation) String() string {
+	if op.Kind == OpRename {
+		return fmt.Sprintf("%s %s -> %s", op.Kind, op.Name, op.NewName)
+	}
+	return fmt.Sprintf("%s %s", op.Kind, op.Name)
+}
+
+// Apply performs a list of operations in order. The whole list is validated
+// against the sections each earlier operation leaves behind before anything is
+// changed, and if an operation still fails while being applied the File is
+// restored to its previous state, so either every operation takes effect or none.
+//
+// Parameters:
+//   - ops: The operations to perform, in order.
+//
+// Returns:
+//   - An error naming the first operation that is invalid or fails.
+func (f *File) Apply(ops []Operation) error {
+	if err := f.validateOperations(ops); err != nil {
+		return err
+	}
+	saved := f.snapshot()
+	for i, op := range ops {
+		if err := f.apply(op); err != nil {
+			f.restore(saved)
+			return fmt.Errorf("operation %d (%s): %w", i+1, op, err)
+		}
+	}
+	return nil
+}
+
+// plannedSection is a section as validateOperations expects it to be after
+// the operations checked so far.
+type plannedSection struct {
+	name    string
+	index   int // Index in f.Sections, or -1 for a section added by an operation
+	removed bool
+}
+
+// validateOperations checks that every operation refers to sections that will
+// exist at that point and that its options are valid. Cascading removals take
+// the sections that depend on the removed one with them, as RemoveSectionCascade does.
+func (f *File) validateOperations(ops []Operation) error {
+	planned := make([]*plannedSection, len(f.Sections))
+	for i, s := range f.Sections {
+		planned[i] = &plannedSection{name: s.Name, index: i}
+	}
+	lookup := func(name string) *plannedSection {
+		for _, p := range planned {
+			if !p.removed && p.name == name {
+				return p
+			}
+		}
+		return nil
+	}
+	for i, op := range ops {
+		err := func() error {
+			if op.Name == "" {
+				return fmt.Errorf("%w: missing section name", ErrInvalidArgument)
+			}
+			p := lookup(op.Name)
+			switch op.Kind {
+			case OpAdd:
+				if p != nil {
+					return fmt.Errorf("%w: %s", ErrSectionExists, op.Name)
+				}
+				planned = append(planned, &plannedSection{name: op.Name, index: -1})
+			case OpReplace, OpSetFlags, OpRemove:
+				if p == nil {
+					return fmt.Errorf("%w: %s", ErrSectionNotFound, op.Name)
+				}
+				if op.Kind == OpRemove {
+					p.removed = true
+					if op.Cascade && p.index >= 0 {
+						for _, j := range f.dependents(p.index) {
+							planned[j].removed = true
+						}
+					}
+				}
+				if op.Kind == OpSetFlags && op.Options.Flags == nil {
+					return fmt.Errorf("%w: no flags given", ErrInvalidArgument)
+				}
+			case OpRename:
+				if p == nil {
+					return fmt.Errorf("%w: %s", ErrSectionNotFound, op.Name)
+				}
+				if op.NewName == "" {
+					return fmt.Errorf("%w: missing new section name", ErrInvalidArgument)
+				}
+				if lookup(op.NewName) != nil {
+					return fmt.Errorf("%w: %s", ErrSectionExists, op.NewName)
+				}
+				p.name = op.NewName
+			default:
+				return fmt.Errorf("%w: unknown operation %q", ErrInvalidArgument, op.Kind)
+			}
+			return op.Options.validate(f)
+		}()
+		if err != nil {
+			return fmt.Errorf("operation %d (%s): %w", i+1, op, err)
+		}
+	}
+	return nil
+}
+
+// apply performs a single operation.
+func (f *File) apply(op Operation) error {
+	switch op.Kind {
+	case OpAdd:
+		if f.Section(op.Name) != nil {
+			return fmt.Errorf("%w: %s", ErrSectionExists, op.Name)
+		}
+		_, err := f.AddOrReplaceSectionWithOptions(op.Name, op.Data, op.Options)
+		return err
+	case OpReplace:
+		if f.Section(op.Name) == nil {
+			return fmt.Errorf("%w: %s", ErrSectionNotFound, op.Name)
+		}
+		_, err := f.AddOrReplaceSectionWithOptions(op.Name, op.Data, op.Options)
+		return err
+	case OpRemove:
+		if op.Cascade {
+			_, err := f.RemoveSectionCascade(op.Name)
+			return err
+		}
+		return f.RemoveSection(op.Name)
+	case OpRename:
+		return f.RenameSectionWithOptions(op.Name, op.NewName, op.Options)
+	case OpSetFlags:
+		return f.UpdateSection(op.Name, op.Options)
+	}
+	return fmt.Errorf("%w: unknown operation %q", ErrInvalidArgument, op.Kind)
+}
+
+// fileState is a copy of everything an Operation can change.
+type fileState struct {
+	sections []*Section
+	headers  []Section
+	segments []*Segment
+	progs    []Segment
+	shstrtab *Section
+	overlay  []byte
+}
+
+// snapshot records the current state of the file for restore.
+func (f *File) snapshot() fileState {
+	st := fileState{
+		sections: slices.Clone(f.Sections),
+		segments: slices.Clone(f.Segments),
+		shstrtab: f.shstrtab,
+		overlay:  f.overlay,
+	}
+	for _, s := range f.Sections {
+		st.headers = append(st.headers, *s)
+	}
+	for _, p := range f.Segments {
+		st.progs = append(st.progs, *p)
+	}
+	return st
+}
+
+// restore undoes every change made since st was recorded.
+func (f *File) restore(st fileState) {
+	f.Sections = st.sections
+	for i, s := range f.Sections {
+		*s = st.headers[i]
+	}
+	f.Segments = st.segments
+	for i, p := range f.Segments {
+		*p = st.progs[i]
+	}
+	f.shstrtab = st.shstrtab
+	f.overlay = st.overlay
+}
+
+// manifestOp is a single operation as written in a JSON manifest.
+type manifestOp struct {
+	Op      OperationKind `json:"op"`
+	Name    string        `json:"name"`
+	To      string        `json:"to"`
+	Data    *string       `json:"data"`
+	Hex     *string       `json:"hex"`
+	Base64  *string       `json:"base64"`
+	File    *string       `json:"file"`
+	Type    *string       `json:"type"`
+	Flags   *string       `json:"flags"`
+	Align   *uint64       `json:"align"`
+	Load    bool          `json:"load"`
+	Cascade bool          `json:"cascade"`
+}
+
+// ParseManifest decodes a JSON manifest of operations for File.Apply. The
+// manifest is either an array of operations or an object holding that array
+// under "operations". Each operation has the form
+//
+//	{"op": "add", "name": ".note.x", "file": "x.bin", "type": "note", "flags": "alloc", "align": 4}
+//	{"op": "replace", "name": ".comment", "data": "text"}
+//	{"op": "remove", "name": ".foo", "cascade": true}
+//	{"op": "rename", "name": ".a", "to": ".b", "flags": "alloc"}
+//	{"op": "set-flags", "name": ".b", "flags": "alloc,write"}
+//
+// Contents for add and replace are given by exactly one of "data" (text),
+// "hex", "base64" or "file". Types and flags use the names accepted by
+// ParseSectionType and ParseSectionFlags.
+//
+// Parameters:
+//   - manifest: The JSON manifest.
+//   - dir: The directory that relative "file" paths are resolved against.
+//
+// Returns:
+//   - The decoded operations, in order.
+//   - An error if the manifest is malformed or a data file cannot be read.
+func ParseManifest(manifest []byte, dir string) ([]Operation, error) {
+	var entries []manifestOp
+	if err := json.Unmarshal(manifest, &entries); err != nil {
+		var doc struct {
+			Operations []manifestOp `json:"operations"`
+		}
+		if err := json.Unmarshal(manifest, &doc); err != nil {
+			return nil, fmt.Errorf("%w: error decoding manifest: %v", ErrInvalidArgument, err)
+		}
+		entries = doc.Operations
+	}
+	ops := make([]Operation, 0, len(entries))
+	for i, e := range entries {
+		op, err := e.operation(dir)
+		if err != nil {
+			return nil, fmt.Errorf("manifest operation %d: %w", i+1, err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// operation converts a manifest entry to an Operation.
+func (e manifestOp) operation(dir string) (Operation, error) {
+	op := Operation{Kind: e.Op, Name: e.Name, NewName: e.To, Cascade: e.Cascade}
+	op.Options.Load = e.Load
+	op.Options.Addralign = e.Align
+	if e.Type != nil {
+		t, err := ParseSectionType(*e.Type)
+		if err != nil {
+			return op, err
+		}
+		op.Options.Type = &t
+	}
+	if e.Flags != nil {
+		fl, err := ParseSectionFlags(*e.Flags)
+		if err != nil {
+			return op, err
+		}
+		op.Options.Flags = &fl
+	}
+
+	sources := 0
+	for _, src := range []*string{e.Data, e.Hex, e.Base64, e.File} {
+		if src != nil {
+			sources++
+		}
+	}
+	if e.Op != OpAdd && e.Op != OpReplace {
+		if sources > 0 {
+			return op, fmt.Errorf("%w: %s takes no section data", ErrInvalidArgument, e.Op)
+		}
+		return op, nil
+	}
+	if sources != 1 {
+		return op, fmt.Errorf("%w: %s needs exactly one of data, hex, base64 or file", ErrInvalidArgument, e.Op)
+	}
+	var err error
+	switch {
+	case e.Data != nil:
+		op.Data = []byte(*e.Data)
+	case e.Hex != nil:
+		op.Data, err = hex.DecodeString(*e.Hex)
+	case e.Base64 != nil:
+		op.Data, err = base64.StdEncoding.DecodeString(*e.Base64)
+	case e.File != nil:
+		op.Data, err = readDataFile(*e.File, dir)
+	}
+	if err != nil {
+		return op, fmt.Errorf("error reading section data: %w", err)
+	}
+	return op, nil
+}
+
+// readDataFile reads a regular file named in a manifest, refusing devices and
+// files larger than DefaultLimits.MaxFileSize.
+func readDataFile(path, dir string) ([]byte, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s is not a regular file", ErrInvalidArgument, path)
+	}
+	if err := checkLimit("MaxFileSize", uint64(fi.Size()), DefaultLimits.MaxFileSize); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// ApplyOperations performs a list of operations on the ELF data in a single
+// pass; see File.Apply.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//   - ops: The operations to perform, in order.
+//
+// Returns:
+//   - A byte slice containing the modified ELF file data.
+//   - An error if the ELF data is invalid or any operation fails.
+func ApplyOperations(elfData []byte, ops []Operation) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Apply(ops); err != nil {
+		return nil, err
+	}
+	return f.Bytes()
+}
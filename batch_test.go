@@ -0,0 +1,189 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyValidation(t *testing.T) {
+	alloc := elf.SHF_ALLOC
+	tests := []struct {
+		name  string
+		ops   []Operation
+		check error
+	}{
+		{"cascade removes dependents", []Operation{
+			{Kind: OpRemove, Name: ".text.a", Cascade: true},
+			{Kind: OpReplace, Name: ".rela.text.a", Data: []byte("x")},
+		}, ErrSectionNotFound},
+		{"cascade removes transitive dependents", []Operation{
+			{Kind: OpRemove, Name: ".strtab", Cascade: true},
+			{Kind: OpSetFlags, Name: ".rela.text.a", Options: SectionOptions{Flags: &alloc}},
+		}, ErrSectionNotFound},
+		{"removed before", []Operation{
+			{Kind: OpRemove, Name: ".rodata"},
+			{Kind: OpRemove, Name: ".rodata"},
+		}, ErrSectionNotFound},
+		{"renamed before", []Operation{
+			{Kind: OpRename, Name: ".data", NewName: ".data.b"},
+			{Kind: OpReplace, Name: ".data", Data: []byte("x")},
+		}, ErrSectionNotFound},
+		{"rename to existing", []Operation{{Kind: OpRename, Name: ".data", NewName: ".text.a"}}, ErrSectionExists},
+		{"add existing", []Operation{{Kind: OpAdd, Name: ".data", Data: []byte("x")}}, ErrSectionExists},
+		{"added before", []Operation{
+			{Kind: OpAdd, Name: ".x", Data: []byte("x")},
+			{Kind: OpAdd, Name: ".x", Data: []byte("y")},
+		}, ErrSectionExists},
+		{"missing name", []Operation{{Kind: OpRemove}}, ErrInvalidArgument},
+		{"missing new name", []Operation{{Kind: OpRename, Name: ".data"}}, ErrInvalidArgument},
+		{"missing flags", []Operation{{Kind: OpSetFlags, Name: ".data"}}, ErrInvalidArgument},
+		{"unknown kind", []Operation{{Kind: "move", Name: ".data"}}, ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, f := buildFile(t, groupBuilder("x86_64"))
+			err := f.Apply(tt.ops)
+			if !errors.Is(err, tt.check) {
+				t.Fatalf("error = %v, want %v", err, tt.check)
+			}
+			if want := fmt.Sprintf("operation %d ", len(tt.ops)); !strings.HasPrefix(err.Error(), want) {
+				t.Errorf("error %q does not name operation %d", err, len(tt.ops))
+			}
+			if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+				t.Errorf("refused operations changed the file: %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyCascade(t *testing.T) {
+	_, f := buildFile(t, groupBuilder("x86_64"))
+	// The name of a section removed by a cascade is free again
+	err := f.Apply([]Operation{
+		{Kind: OpRemove, Name: ".text.a", Cascade: true},
+		{Kind: OpAdd, Name: ".rela.text.a", Data: []byte("x")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := parseFile(t, out)
+	checkVerify(t, g)
+	if g.Section(".text.a") != nil || g.Section(".rela.text.a").Type != elf.SHT_PROGBITS {
+		t.Errorf("sections after cascade: %v", g.SectionTable())
+	}
+}
+
+func TestApplyRollback(t *testing.T) {
+	data, f := buildFile(t, groupBuilder("x86_64"))
+	alloc := elf.SHF_ALLOC
+	ops := []Operation{
+		{Kind: OpAdd, Name: ".x", Data: []byte("x")},
+		{Kind: OpReplace, Name: ".data", Data: []byte("longer data")},
+		{Kind: OpRename, Name: ".text.a", NewName: ".text.b"},
+		{Kind: OpSetFlags, Name: ".rodata", Options: SectionOptions{Flags: &alloc}},
+		{Kind: OpRemove, Name: ".group"},
+		// Valid in the plan, but symbol d still refers to .data
+		{Kind: OpRemove, Name: ".data"},
+	}
+	err := f.Apply(ops)
+	if !errors.Is(err, ErrSectionReferenced) || !strings.HasPrefix(err.Error(), "operation 6") {
+		t.Fatalf("error = %v, want %v in operation 6", err, ErrSectionReferenced)
+	}
+	if f.Section(".x") != nil || f.Section(".text.b") != nil || f.Section(".text.a") == nil || f.Section(".group") == nil {
+		t.Errorf("sections after rollback: %v", f.SectionTable())
+	}
+	if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("failed Apply changed the file: %v", err)
+	}
+
+	// Without the failing operation every change takes effect
+	if err := f.Apply(ops[:len(ops)-1]); err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := parseFile(t, out)
+	checkVerify(t, g)
+	got, err := g.Section(".data").Data()
+	if err != nil || string(got) != "longer data" || g.Section(".x") == nil || g.Section(".text.b") == nil ||
+		g.Section(".group") != nil || g.Section(".rodata").Flags != alloc {
+		t.Errorf("sections after Apply: %v", g.SectionTable())
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "x.bin"), []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	note, flags, align := elf.SHT_NOTE, elf.SHF_ALLOC|elf.SHF_WRITE, uint64(4)
+	ops := `[
+		{"op": "add", "name": ".a", "file": "x.bin", "type": "note", "flags": "alloc,write", "align": 4, "load": true},
+		{"op": "replace", "name": ".b", "data": "text"},
+		{"op": "replace", "name": ".c", "hex": "0102"},
+		{"op": "replace", "name": ".d", "base64": "AwQ="},
+		{"op": "remove", "name": ".e", "cascade": true},
+		{"op": "rename", "name": ".f", "to": ".g"},
+		{"op": "set-flags", "name": ".g", "flags": "alloc,write"}
+	]`
+	want := []Operation{
+		{Kind: OpAdd, Name: ".a", Data: []byte("file"), Options: SectionOptions{Type: &note, Flags: &flags, Addralign: &align, Load: true}},
+		{Kind: OpReplace, Name: ".b", Data: []byte("text")},
+		{Kind: OpReplace, Name: ".c", Data: []byte{1, 2}},
+		{Kind: OpReplace, Name: ".d", Data: []byte{3, 4}},
+		{Kind: OpRemove, Name: ".e", Cascade: true},
+		{Kind: OpRename, Name: ".f", NewName: ".g"},
+		{Kind: OpSetFlags, Name: ".g", Options: SectionOptions{Flags: &flags}},
+	}
+	for name, manifest := range map[string]string{
+		"array":  ops,
+		"object": `{"operations": ` + ops + `}`,
+	} {
+		got, err := ParseManifest([]byte(manifest), dir)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: operations = %+v, want %+v", name, got, want)
+		}
+	}
+
+	bad := []struct {
+		manifest string
+		check    error
+	}{
+		{`{"op": "add"`, ErrInvalidArgument},
+		{`"add"`, ErrInvalidArgument},
+		{`[{"op": "add", "name": ".a"}]`, ErrInvalidArgument},
+		{`[{"op": "add", "name": ".a", "data": "x", "hex": "00"}]`, ErrInvalidArgument},
+		{`[{"op": "remove", "name": ".a", "data": "x"}]`, ErrInvalidArgument},
+		{`[{"op": "add", "name": ".a", "data": "x", "type": "bogus"}]`, ErrInvalidArgument},
+		{`[{"op": "set-flags", "name": ".a", "flags": "bogus"}]`, ErrInvalidArgument},
+		{`[{"op": "add", "name": ".a", "file": "."}]`, ErrInvalidArgument},
+		{`[{"op": "add", "name": ".a", "file": "missing"}]`, fs.ErrNotExist},
+	}
+	for _, tt := range bad {
+		if _, err := ParseManifest([]byte(tt.manifest), dir); !errors.Is(err, tt.check) {
+			t.Errorf("ParseManifest(%s): error = %v, want %v", tt.manifest, err, tt.check)
+		}
+	}
+	for _, manifest := range []string{`[{"op": "add", "name": ".a", "hex": "zz"}]`, `[{"op": "add", "name": ".a", "base64": "!"}]`} {
+		if _, err := ParseManifest([]byte(manifest), dir); err == nil {
+			t.Errorf("ParseManifest(%s) accepted invalid data", manifest)
+		}
+	}
+}
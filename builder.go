@@ -0,0 +1,629 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// Builder produces an ELF file from scratch, for any class, byte order,
+// machine and file type. It is meant for test fixtures and small generated
+// artifacts such as relocatable objects that carry data blobs.
+//
+// Sections are written in the order given, after the ELF header and program
+// header table. Notes are appended to the SHT_NOTE section they name, which is
+// created after the given sections if it does not exist. When there are
+// symbols, .symtab and .strtab follow, and .shstrtab and the section header
+// table always come last.
+//
+// Allocated sections covered by a PT_LOAD segment are given addresses by the
+// Builder, starting at BaseAddr and congruent to their file offsets modulo
+// PageSize. Every other segment is sized to span the sections it lists.
+type Builder struct {
+	Class    elf.Class
+	Data     elf.Data
+	OSABI    elf.OSABI
+	Type     elf.Type
+	Machine  elf.Machine
+	Flags    uint32 // e_flags
+	Entry    uint64 // e_entry, unless EntrySymbol is set
+	BaseAddr uint64 // Address of the first PT_LOAD segment
+	PageSize uint64 // Alignment of PT_LOAD segments; 0x1000 if zero
+
+	// EntrySymbol names a symbol whose value is used as e_entry.
+	EntrySymbol string
+
+	Sections []BuildSection
+	Segments []BuildSegment
+	Symbols  []BuildSymbol
+	Notes    []BuildNote
+}
+
+// BuildSection describes a section to create.
+type BuildSection struct {
+	Name      string
+	Type      elf.SectionType
+	Flags     elf.SectionFlag
+	Addr      uint64 // Address of an allocated section not covered by a PT_LOAD segment
+	Addralign uint64
+	Entsize   uint64
+	Link      string // Name of the section whose index is stored in sh_link, e.g. ".symtab"
+	Info      uint32
+	InfoLink  string // Name of the section whose index is stored in sh_info; overrides Info
+	Data      []byte
+	Size      uint64 // Size of an SHT_NOBITS section
+}
+
+// BuildSegment describes a program header to create.
+type BuildSegment struct {
+	Type     elf.ProgType
+	Flags    elf.ProgFlag
+	Sections []string // Names of the covered sections, consecutive in section order
+	Align    uint64   // Segment alignment; defaults to PageSize for PT_LOAD and the largest section alignment otherwise
+	// Headers makes the first PT_LOAD segment also map the ELF header and the
+	// program header table, as linkers do for executables.
+	Headers bool
+}
+
+// BuildSymbol describes a .symtab entry to create. Local symbols are written
+// before global and weak ones regardless of their order in Builder.Symbols.
+type BuildSymbol struct {
+	Name       string
+	Section    string // Name of the defining section; "" for Shndx
+	Shndx      elf.SectionIndex
+	Value      uint64 // Offset into Section; the section address is added in ET_EXEC and ET_DYN files
+	Size       uint64
+	Bind       elf.SymBind
+	Type       elf.SymType
+	Visibility elf.SymVis
+}
+
+// BuildNote describes a note record to append to an SHT_NOTE section.
+type BuildNote struct {
+	Section string // Name of the note section; ".note" if empty
+	Name    string // Owner, e.g. "GNU"
+	Type    uint32
+	Desc    []byte
+}
+
+// NewBuilder returns a Builder for a file of the given class, byte order,
+// type and machine with the conventional base address for its type.
+//
+// Parameters:
+//   - class: elf.ELFCLASS32 or elf.ELFCLASS64.
+//   - data: elf.ELFDATA2LSB or elf.ELFDATA2MSB.
+//   - typ: The file type, e.g. elf.ET_REL or elf.ET_EXEC.
+//   - machine: The target machine, e.g. elf.EM_X86_64.
+//
+// Returns:
+//   - A pointer to the new Builder.
+func NewBuilder(class elf.Class, data elf.Data, typ elf.Type, machine elf.Machine) *Builder {
+	b := &Builder{Class: class, Data: data, Type: typ, Machine: machine, PageSize: 0x1000}
+	if typ == elf.ET_EXEC {
+		b.BaseAddr = 0x400000
+		if class == elf.ELFCLASS32 {
+			b.BaseAddr = 0x8048000
+		}
+	}
+	return b
+}
+
+// builtSection is a section being laid out by Builder.Bytes.
+type builtSection struct {
+	BuildSection
+	sec     *Section
+	index   int         // Index of sec in the section header table
+	seg     int         // Index of the covering PT_LOAD segment, or -1
+	strings stringTable // Contents of .strtab, indexed by name
+}
+
+// Build produces the ELF file and parses it.
+//
+// Returns:
+//   - A pointer to the parsed File.
+//   - An error if the description is invalid.
+func (b *Builder) Build() (*File, error) {
+	data, err := b.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Bytes produces the ELF file.
+//
+// Returns:
+//   - A byte slice containing the ELF file data.
+//   - An error if the description is invalid, e.g. names an unknown section.
+func (b *Builder) Bytes() ([]byte, error) {
+	f := &File{Limits: DefaultLimits}
+	f.Class, f.Data, f.Version, f.OSABI = b.Class, b.Data, elf.EV_CURRENT, b.OSABI
+	f.Type, f.Machine, f.flags = b.Type, b.Machine, b.Flags
+	copy(f.ident[:], elf.ELFMAG)
+	switch b.Class {
+	case elf.ELFCLASS64:
+		f.ehsize = 64
+	case elf.ELFCLASS32:
+		f.ehsize = 52
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedClass, b.Class)
+	}
+	switch b.Data {
+	case elf.ELFDATA2LSB:
+		f.ByteOrder = binary.LittleEndian
+	case elf.ELFDATA2MSB:
+		f.ByteOrder = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedEncoding, b.Data)
+	}
+
+	sections, err := b.sections(f)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*builtSection, len(sections))
+	for _, s := range sections {
+		byName[s.Name] = s
+	}
+	for _, s := range sections[1:] {
+		if s.Link != "" {
+			l := byName[s.Link]
+			if l == nil {
+				return nil, fmt.Errorf("%w: section %s links to unknown section %s", ErrInvalidArgument, s.Name, s.Link)
+			}
+			s.sec.Link = uint32(l.index)
+		}
+		if s.InfoLink != "" {
+			l := byName[s.InfoLink]
+			if l == nil {
+				return nil, fmt.Errorf("%w: section %s refers to unknown section %s", ErrInvalidArgument, s.Name, s.InfoLink)
+			}
+			s.sec.Info = uint32(l.index)
+		}
+	}
+	if err := b.assignSegments(sections, byName); err != nil {
+		return nil, err
+	}
+
+	plan := &LayoutPlan{}
+	if len(b.Segments) > 0 {
+		plan.ProgramHeaderOffset = uint64(f.ehsize)
+	}
+	end, err := b.place(f, sections, plan)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.writeSymbols(f, byName); err != nil {
+		return nil, err
+	}
+	plan.SectionHeaderOffset = alignUp(end, f.wordSize())
+	plan.Size = plan.SectionHeaderOffset + uint64(len(f.Sections))*uint64(f.sectionHeaderSize())
+	if err := checkLimit("MaxOutputSize", plan.Size, f.Limits.MaxOutputSize); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, plan.Size)
+	var shdrBuf bytes.Buffer
+	for i, s := range f.Sections {
+		if s.Type != elf.SHT_NOBITS {
+			copy(out[s.Offset:], s.data)
+		}
+		if i == 0 {
+			s = f.extendedNull(s)
+		}
+		if err := f.writeSectionHeader(&shdrBuf, s, s.Offset); err != nil {
+			return nil, err
+		}
+	}
+	copy(out[plan.SectionHeaderOffset:], shdrBuf.Bytes())
+	var phdrBuf bytes.Buffer
+	for _, p := range plan.progs {
+		if err := f.writeProgHeader(&phdrBuf, p); err != nil {
+			return nil, err
+		}
+	}
+	copy(out[plan.ProgramHeaderOffset:], phdrBuf.Bytes())
+	var hdrBuf bytes.Buffer
+	if err := f.writeHeader(&hdrBuf, plan); err != nil {
+		return nil, err
+	}
+	copy(out, hdrBuf.Bytes())
+	return out, nil
+}
+
+// sections creates the section list of f: the null section, the given
+// sections, note sections, the symbol table and .shstrtab, with names and
+// note contents filled in.
+func (b *Builder) sections(f *File) ([]*builtSection, error) {
+	newSection := func(bs BuildSection) *builtSection {
+		s := &builtSection{BuildSection: bs, index: len(f.Sections), seg: -1}
+		s.sec = &Section{
+			Name: bs.Name, Type: bs.Type, Flags: bs.Flags, Addr: bs.Addr, Addralign: bs.Addralign,
+			Entsize: bs.Entsize, Info: bs.Info, file: f, added: true, dirty: true,
+			data: append([]byte(nil), bs.Data...),
+		}
+		s.sec.Size = uint64(len(bs.Data))
+		if bs.Type == elf.SHT_NOBITS {
+			s.sec.Size = bs.Size
+		}
+		f.Sections = append(f.Sections, s.sec)
+		return s
+	}
+	sections := []*builtSection{newSection(BuildSection{})}
+	byName := make(map[string]*builtSection, len(b.Sections))
+	find := func(name string) *builtSection {
+		return byName[name]
+	}
+	for _, bs := range b.Sections {
+		if bs.Name == "" {
+			return nil, fmt.Errorf("%w: section name cannot be empty", ErrInvalidArgument)
+		}
+		if find(bs.Name) != nil {
+			return nil, fmt.Errorf("%w: %s", ErrSectionExists, bs.Name)
+		}
+		byName[bs.Name] = newSection(bs)
+		sections = append(sections, byName[bs.Name])
+	}
+
+	for _, n := range b.Notes {
+		name := n.Section
+		if name == "" {
+			name = ".note"
+		}
+		s := find(name)
+		if s == nil {
+			s = newSection(BuildSection{Name: name, Type: elf.SHT_NOTE, Flags: elf.SHF_ALLOC, Addralign: 4})
+			byName[name] = s
+			sections = append(sections, s)
+		}
+		if s.sec.Type != elf.SHT_NOTE {
+			return nil, fmt.Errorf("%w: note section %s is not of type SHT_NOTE", ErrInvalidArgument, name)
+		}
+		s.sec.data = appendNote(f.ByteOrder, s.sec.data, n.Name, n.Type, n.Desc, noteAlign(s.sec.Addralign))
+		s.sec.Size = uint64(len(s.sec.data))
+	}
+
+	if len(b.Symbols) > 0 {
+		for _, name := range []string{".symtab", ".strtab"} {
+			if find(name) != nil {
+				return nil, fmt.Errorf("%w: %s is generated from Builder.Symbols", ErrSectionExists, name)
+			}
+		}
+		// Reserve room for the symbol table so that the sections after it can be placed
+		symtab := newSection(BuildSection{
+			Name: ".symtab", Type: elf.SHT_SYMTAB, Addralign: f.wordSize(),
+			Entsize: uint64(f.symbolSize()), Link: ".strtab",
+			Data: make([]byte, (len(b.Symbols)+1)*f.symbolSize()),
+		})
+		strtab := newSection(BuildSection{Name: ".strtab", Type: elf.SHT_STRTAB, Addralign: 1})
+		for _, bs := range b.Symbols {
+			strtab.strings.add(bs.Name)
+		}
+		strtab.sec.data = strtab.strings.data
+		strtab.sec.Size = uint64(len(strtab.sec.data))
+		sections = append(sections, symtab, strtab)
+	}
+
+	if find(".shstrtab") != nil {
+		return nil, fmt.Errorf("%w: .shstrtab is generated", ErrSectionExists)
+	}
+	shstrtab := newSection(BuildSection{Name: ".shstrtab", Type: elf.SHT_STRTAB, Addralign: 1})
+	sections = append(sections, shstrtab)
+	f.shstrtab = shstrtab.sec
+	var names stringTable
+	for _, s := range f.Sections {
+		s.nameOff = names.add(s.Name)
+	}
+	f.shstrtab.data = names.data
+	f.shstrtab.Size = uint64(len(names.data))
+	return sections, nil
+}
+
+// assignSegments records the PT_LOAD segment covering each section and checks
+// that every segment lists existing, consecutive sections.
+func (b *Builder) assignSegments(sections []*builtSection, byName map[string]*builtSection) error {
+	for i, seg := range b.Segments {
+		first, last := -1, -1
+		for _, name := range seg.Sections {
+			s := byName[name]
+			if s == nil {
+				return fmt.Errorf("%w: segment %d covers unknown section %s", ErrInvalidArgument, i, name)
+			}
+			idx := s.index
+			if first != -1 && idx != last+1 {
+				return fmt.Errorf("%w: sections of segment %d are not consecutive", ErrInvalidArgument, i)
+			}
+			if first == -1 {
+				first = idx
+			}
+			last = idx
+			if seg.Type != elf.PT_LOAD {
+				continue
+			}
+			if s.Flags&elf.SHF_ALLOC == 0 {
+				return fmt.Errorf("%w: PT_LOAD segment %d covers non-allocated section %s", ErrInvalidArgument, i, name)
+			}
+			if s.seg != -1 {
+				return fmt.Errorf("%w: section %s is covered by more than one PT_LOAD segment", ErrInvalidArgument, name)
+			}
+			s.seg = i
+		}
+		if seg.Type == elf.PT_LOAD && len(seg.Sections) == 0 && !seg.Headers {
+			return fmt.Errorf("%w: PT_LOAD segment %d covers nothing", ErrInvalidArgument, i)
+		}
+		if seg.Headers && slices.IndexFunc(b.Segments, func(p BuildSegment) bool { return p.Type == elf.PT_LOAD }) != i {
+			return fmt.Errorf("%w: only the first PT_LOAD segment can map the headers", ErrInvalidArgument)
+		}
+	}
+	return nil
+}
+
+// loadState tracks the placement of a PT_LOAD segment.
+type loadState struct {
+	started         bool
+	off, vaddr      uint64 // Start of the segment
+	fileEnd, memEnd uint64
+	nobits          bool // An SHT_NOBITS section has been placed
+}
+
+// place assigns offsets and addresses to every section and builds the program
+// headers into plan.
+//
+// Returns:
+//   - The end offset of the last section.
+//   - An error if a segment cannot be laid out.
+func (b *Builder) place(f *File, sections []*builtSection, plan *LayoutPlan) (uint64, error) {
+	page := b.PageSize
+	if page == 0 {
+		page = 0x1000
+	}
+	if page&(page-1) != 0 {
+		return 0, fmt.Errorf("%w: page size %d is not a power of two", ErrInvalidArgument, page)
+	}
+	phtSize := uint64(len(b.Segments)) * uint64(f.progHeaderSize())
+	off := plan.ProgramHeaderOffset + phtSize
+	if plan.ProgramHeaderOffset == 0 {
+		off = uint64(f.ehsize)
+	}
+	loads := make([]loadState, len(b.Segments))
+	addr := b.BaseAddr
+	for i, seg := range b.Segments {
+		if seg.Headers {
+			loads[i] = loadState{started: true, vaddr: b.BaseAddr, fileEnd: off, memEnd: b.BaseAddr + off}
+			addr = b.BaseAddr + off
+		}
+	}
+
+	for _, s := range sections[1:] {
+		sec := s.sec
+		off = alignUp(off, max(1, sec.Addralign))
+		sec.Offset = off
+		nobits := sec.Type == elf.SHT_NOBITS
+		if s.seg != -1 {
+			l := &loads[s.seg]
+			switch {
+			case !l.started:
+				l.started = true
+				l.off = off
+				l.vaddr = alignUp(addr, page) + off%page
+				sec.Addr = l.vaddr
+			case nobits:
+				sec.Addr = alignUp(max(addr, l.memEnd), max(1, sec.Addralign))
+			case l.nobits:
+				return 0, fmt.Errorf("%w: section %s follows an SHT_NOBITS section in segment %d", ErrInvalidArgument, sec.Name, s.seg)
+			default:
+				sec.Addr = l.vaddr + off - l.off
+			}
+			l.nobits = l.nobits || nobits
+			l.memEnd = sec.Addr + sec.Size
+			if !nobits {
+				l.fileEnd = off + sec.Size
+			}
+			addr = l.memEnd
+		}
+		if !nobits {
+			off += sec.Size
+		}
+	}
+
+	for i, seg := range b.Segments {
+		p := elf.ProgHeader{Type: seg.Type, Flags: seg.Flags, Align: seg.Align}
+		switch seg.Type {
+		case elf.PT_LOAD:
+			l := loads[i]
+			p.Off, p.Vaddr = l.off, l.vaddr
+			p.Filesz, p.Memsz = max(l.fileEnd, l.off)-l.off, l.memEnd-l.vaddr
+			if p.Align == 0 {
+				p.Align = page
+			}
+		case elf.PT_PHDR:
+			p.Off, p.Filesz, p.Memsz = plan.ProgramHeaderOffset, phtSize, phtSize
+			if slices.ContainsFunc(b.Segments, func(p BuildSegment) bool { return p.Headers }) {
+				p.Vaddr = b.BaseAddr + p.Off
+			}
+			if p.Align == 0 {
+				p.Align = f.wordSize()
+			}
+		default:
+			b.span(sections, seg, &p)
+		}
+		p.Paddr = p.Vaddr
+		if err := f.checkSegment(p); err != nil {
+			return 0, fmt.Errorf("segment %d: %w", i, err)
+		}
+		plan.progs = append(plan.progs, p)
+	}
+	return off, nil
+}
+
+// span sizes a segment other than PT_LOAD and PT_PHDR to cover its sections.
+func (b *Builder) span(sections []*builtSection, seg BuildSegment, p *elf.ProgHeader) {
+	names := make(map[string]bool, len(seg.Sections))
+	for _, name := range seg.Sections {
+		names[name] = true
+	}
+	var covered []*Section
+	for _, s := range sections {
+		if names[s.Name] {
+			covered = append(covered, s.sec)
+		}
+	}
+	if len(covered) == 0 {
+		return
+	}
+	first := covered[0]
+	p.Off, p.Vaddr = first.Offset, first.Addr
+	align := uint64(1)
+	for _, s := range covered {
+		if s.Type != elf.SHT_NOBITS {
+			p.Filesz = s.Offset + s.Size - p.Off
+		}
+		if s.Flags&elf.SHF_ALLOC != 0 {
+			p.Memsz = max(p.Memsz, s.Addr+s.Size-p.Vaddr)
+		}
+		align = max(align, s.Addralign)
+	}
+	if p.Align == 0 {
+		p.Align = align
+	}
+}
+
+// writeSymbols encodes Builder.Symbols into .symtab, locals first, and
+// resolves EntrySymbol.
+func (b *Builder) writeSymbols(f *File, byName map[string]*builtSection) error {
+	var entry *uint64
+	if len(b.Symbols) > 0 {
+		symtab, strtab := byName[".symtab"].sec, byName[".strtab"]
+		syms := slices.Clone(b.Symbols)
+		slices.SortStableFunc(syms, func(a, b BuildSymbol) int {
+			return boolCompare(a.Bind != elf.STB_LOCAL, b.Bind != elf.STB_LOCAL)
+		})
+		var buf bytes.Buffer
+		buf.Write(make([]byte, f.symbolSize()))
+		symtab.Info = uint32(len(syms) + 1)
+		for i, bs := range syms {
+			if bs.Bind != elf.STB_LOCAL && symtab.Info == uint32(len(syms)+1) {
+				symtab.Info = uint32(i + 1)
+			}
+			shndx, value := uint32(bs.Shndx), bs.Value
+			if bs.Section != "" {
+				s := byName[bs.Section]
+				if s == nil {
+					return fmt.Errorf("%w: symbol %s is defined in unknown section %s", ErrInvalidArgument, bs.Name, bs.Section)
+				}
+				shndx = uint32(s.index)
+				if f.Type != elf.ET_REL {
+					value += s.sec.Addr
+				}
+			}
+			if shndx >= uint32(elf.SHN_LORESERVE) && bs.Section != "" {
+				return fmt.Errorf("%w: symbol %s is defined in section %d, which needs SHT_SYMTAB_SHNDX", ErrInvalidArgument, bs.Name, shndx)
+			}
+			if bs.Name == b.EntrySymbol {
+				entry = &value
+			}
+			sym := symbolEntry{
+				name:  strtab.strings.add(bs.Name),
+				info:  elf.ST_INFO(bs.Bind, bs.Type),
+				other: byte(bs.Visibility) & 0x3,
+				shndx: uint16(shndx),
+				value: value,
+				size:  bs.Size,
+			}
+			if err := f.writeSymbol(&buf, sym); err != nil {
+				return err
+			}
+		}
+		symtab.data = buf.Bytes()
+	}
+	f.Entry = b.Entry
+	if b.EntrySymbol != "" {
+		if entry == nil {
+			return fmt.Errorf("%w: entry symbol %s is not defined", ErrInvalidArgument, b.EntrySymbol)
+		}
+		f.Entry = *entry
+	}
+	return nil
+}
+
+// boolCompare orders false before true.
+func boolCompare(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+// symbolEntry holds the fields of a raw symbol table entry.
+type symbolEntry struct {
+	name        uint32 // Offset of the name in the linked string table
+	info, other byte
+	shndx       uint16
+	value, size uint64
+}
+
+// writeSymbol encodes a single class-specific symbol table entry to buf.
+func (f *File) writeSymbol(buf *bytes.Buffer, sym symbolEntry) error {
+	var st any
+	if f.Class == elf.ELFCLASS64 {
+		st = &elf.Sym64{
+			Name: sym.name, Info: sym.info, Other: sym.other, Shndx: sym.shndx,
+			Value: sym.value, Size: sym.size,
+		}
+	} else {
+		st = &elf.Sym32{
+			Name: sym.name, Value: uint32(sym.value), Size: uint32(sym.size),
+			Info: sym.info, Other: sym.other, Shndx: sym.shndx,
+		}
+	}
+	if err := binary.Write(buf, f.ByteOrder, st); err != nil {
+		return fmt.Errorf("error writing symbol: %w", err)
+	}
+	return nil
+}
+
+// stringTable accumulates a NUL-separated string table that starts with an empty string.
+type stringTable struct {
+	data    []byte
+	offsets map[string]uint32 // Lowest offset of every string and suffix in data
+}
+
+// add returns the offset of str in the table, appending it if it is not
+// already present, including as the suffix of a longer string.
+func (t *stringTable) add(str string) uint32 {
+	if len(t.data) == 0 {
+		t.data = []byte{0}
+	}
+	if t.offsets == nil {
+		t.offsets = make(map[string]uint32)
+		for off := 0; off < len(t.data); {
+			end := bytes.IndexByte(t.data[off:], 0)
+			if end == -1 {
+				break
+			}
+			t.index(string(t.data[off:off+end]), uint32(off))
+			off += end + 1
+		}
+	}
+	if off, ok := t.offsets[str]; ok {
+		return off
+	}
+	off := uint32(len(t.data))
+	t.data = append(append(t.data, str...), 0)
+	t.index(str, off)
+	return off
+}
+
+// index records the offsets of str, which starts at off, and of its suffixes.
+func (t *stringTable) index(str string, off uint32) {
+	for i := 0; i <= len(str); i++ {
+		if _, ok := t.offsets[str[i:]]; !ok {
+			t.offsets[str[i:]] = off + uint32(i)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testBuilders returns an executable and a relocatable object builder for
+// every combination of class and byte order.
+func testBuilders() map[string]*Builder {
+	builders := make(map[string]*Builder)
+	targets := []struct {
+		name    string
+		class   elf.Class
+		data    elf.Data
+		machine elf.Machine
+	}{
+		{"x86_64", elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_X86_64},
+		{"i386", elf.ELFCLASS32, elf.ELFDATA2LSB, elf.EM_386},
+		{"ppc64", elf.ELFCLASS64, elf.ELFDATA2MSB, elf.EM_PPC64},
+		{"mips", elf.ELFCLASS32, elf.ELFDATA2MSB, elf.EM_MIPS},
+		{"mips64el", elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_MIPS},
+	}
+	for _, t := range targets {
+		exec := NewBuilder(t.class, t.data, elf.ET_EXEC, t.machine)
+		exec.Sections = []BuildSection{
+			{Name: ".text", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_EXECINSTR, Addralign: 16, Data: []byte{0x90, 0x90, 0xc3}},
+			{Name: ".note.test", Type: elf.SHT_NOTE, Flags: elf.SHF_ALLOC, Addralign: 4},
+			{Name: ".data", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_WRITE, Addralign: 8, Data: []byte("hello")},
+			{Name: ".bss", Type: elf.SHT_NOBITS, Flags: elf.SHF_ALLOC | elf.SHF_WRITE, Addralign: 8, Size: 0x100},
+			{Name: ".comment", Type: elf.SHT_PROGBITS, Addralign: 1, Data: []byte("elfy\x00")},
+		}
+		exec.Notes = []BuildNote{{Section: ".note.test", Name: "elfy", Type: 1, Desc: []byte{1, 2, 3}}}
+		exec.Segments = []BuildSegment{
+			{Type: elf.PT_PHDR, Flags: elf.PF_R},
+			{Type: elf.PT_LOAD, Flags: elf.PF_R | elf.PF_X, Sections: []string{".text", ".note.test"}, Headers: true},
+			{Type: elf.PT_LOAD, Flags: elf.PF_R | elf.PF_W, Sections: []string{".data", ".bss"}},
+			{Type: elf.PT_NOTE, Flags: elf.PF_R, Sections: []string{".note.test"}},
+			{Type: elf.PT_GNU_STACK, Flags: elf.PF_R | elf.PF_W},
+		}
+		exec.Symbols = []BuildSymbol{
+			{Name: "_start", Section: ".text", Bind: elf.STB_GLOBAL, Type: elf.STT_FUNC, Size: 3},
+			{Name: "greeting", Section: ".data", Bind: elf.STB_LOCAL, Type: elf.STT_OBJECT, Size: 5},
+			{Name: "buffer", Section: ".bss", Value: 0x10, Bind: elf.STB_WEAK, Type: elf.STT_OBJECT, Size: 0x10},
+		}
+		exec.EntrySymbol = "_start"
+		builders[t.name+"/exec"] = exec
+
+		rel := NewBuilder(t.class, t.data, elf.ET_REL, t.machine)
+		rel.Sections = []BuildSection{
+			{Name: ".rodata", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC, Addralign: 1, Data: []byte("blob")},
+		}
+		rel.Symbols = []BuildSymbol{
+			{Name: "blob", Section: ".rodata", Bind: elf.STB_GLOBAL, Type: elf.STT_OBJECT, Size: 4},
+			{Name: "blob_size", Shndx: elf.SHN_ABS, Value: 4, Bind: elf.STB_GLOBAL},
+		}
+		builders[t.name+"/rel"] = rel
+	}
+	return builders
+}
+
+// buildFile produces the file described by b and parses it.
+func buildFile(t *testing.T, b *Builder) ([]byte, *File) {
+	t.Helper()
+	data, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, parseFile(t, data)
+}
+
+// readFile reads and parses the named file of testdata.
+func readFile(t *testing.T, name string) ([]byte, *File) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data, parseFile(t, data)
+}
+
+// parseFile parses data, failing the test if it is not a valid ELF file.
+func parseFile(t *testing.T, data []byte) *File {
+	t.Helper()
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// checkVerify fails the test for every structural error Verify finds in f.
+func checkVerify(t *testing.T, f *File) {
+	t.Helper()
+	for _, p := range f.Verify() {
+		if p.Severity == SeverityError {
+			t.Error(p)
+		}
+	}
+}
+
+func TestBuilder(t *testing.T) {
+	for name, b := range testBuilders() {
+		t.Run(name, func(t *testing.T) {
+			data, f := buildFile(t, b)
+			checkVerify(t, f)
+			out, err := f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, data) {
+				t.Error("unedited file does not round-trip")
+			}
+
+			ef, err := elf.NewFile(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ef.Class != b.Class || ef.Data != b.Data || ef.Machine != b.Machine || ef.Type != b.Type {
+				t.Errorf("header = %v %v %v %v", ef.Class, ef.Data, ef.Machine, ef.Type)
+			}
+			syms, err := ef.Symbols()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(syms) != len(b.Symbols) {
+				t.Fatalf("got %d symbols, want %d", len(syms), len(b.Symbols))
+			}
+			if elf.ST_BIND(syms[0].Info) != elf.STB_LOCAL && b.Type == elf.ET_EXEC {
+				t.Errorf("first symbol %s is not local", syms[0].Name)
+			}
+			for _, sym := range syms {
+				if sym.Name == b.EntrySymbol && sym.Value != ef.Entry {
+					t.Errorf("entry = 0x%x, want 0x%x", ef.Entry, sym.Value)
+				}
+				if sym.Section >= elf.SHN_LORESERVE || sym.Section == elf.SHN_UNDEF {
+					continue
+				}
+				sec := ef.Sections[sym.Section]
+				if b.Type == elf.ET_EXEC && (sym.Value < sec.Addr || sym.Value > sec.Addr+sec.Size) {
+					t.Errorf("symbol %s at 0x%x lies outside %s", sym.Name, sym.Value, sec.Name)
+				}
+			}
+
+			if note := ef.Section(".note.test"); note != nil {
+				raw, err := note.Data()
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := appendNote(ef.ByteOrder, nil, "elfy", 1, []byte{1, 2, 3}, 4)
+				if !bytes.Equal(raw, want) || len(raw) != 12+8+4 {
+					t.Errorf("note = %x, want %x", raw, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		edit  func(b *Builder)
+		check error
+	}{
+		{"class", func(b *Builder) { b.Class = elf.ELFCLASSNONE }, ErrUnsupportedClass},
+		{"duplicate", func(b *Builder) { b.Sections = append(b.Sections, b.Sections[0]) }, ErrSectionExists},
+		{"unknown segment section", func(b *Builder) { b.Segments[1].Sections = []string{".nope"} }, ErrInvalidArgument},
+		{"gap", func(b *Builder) { b.Segments[2].Sections = []string{".text", ".bss"} }, ErrInvalidArgument},
+		{"entry", func(b *Builder) { b.EntrySymbol = "main" }, ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := testBuilders()["x86_64/exec"]
+			tt.edit(b)
+			if _, err := b.Bytes(); !errors.Is(err, tt.check) {
+				t.Errorf("error = %v, want %v", err, tt.check)
+			}
+		})
+	}
+}
+
+// BenchmarkBuilder builds objects with n sections and one symbol in each.
+// The time per entry stays flat as n grows, as building is linear.
+func BenchmarkBuilder(b *testing.B) {
+	for _, n := range []int{1000, 8000, 64000} {
+		bl := NewBuilder(elf.ELFCLASS64, elf.ELFDATA2LSB, elf.ET_REL, elf.EM_X86_64)
+		for i := range n {
+			name := fmt.Sprintf(".s%d", i)
+			bl.Sections = append(bl.Sections, BuildSection{Name: name, Type: elf.SHT_PROGBITS, Addralign: 1, Data: []byte{byte(i)}})
+			bl.Symbols = append(bl.Symbols, BuildSymbol{Name: fmt.Sprintf("sym%d", i), Section: name, Bind: elf.STB_GLOBAL})
+		}
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := bl.Bytes(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/entry")
+		})
+	}
+}
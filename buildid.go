@@ -0,0 +1,192 @@
+package elfy
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ntGNUBuildID is the type of the GNU build ID note.
+const ntGNUBuildID = 3
+
+// BuildIDAlgorithm selects how RecomputeBuildID derives a build ID from the file contents.
+type BuildIDAlgorithm int
+
+const (
+	// BuildIDSHA1 uses the 20-byte SHA-1 hash, like ld --build-id=sha1. This is the default.
+	BuildIDSHA1 BuildIDAlgorithm = iota
+	// BuildIDMD5 uses the 16-byte MD5 hash, like ld --build-id=md5.
+	BuildIDMD5
+	// BuildIDSHA1UUID uses the first 16 bytes of the SHA-1 hash with the
+	// version and variant bits of a version 5 UUID set. It is not a
+	// name-based UUID, as no namespace is hashed, and unlike
+	// ld --build-id=uuid it is not random, so equal files get equal IDs.
+	BuildIDSHA1UUID
+)
+
+// String returns the name of the algorithm.
+func (a BuildIDAlgorithm) String() string {
+	switch a {
+	case BuildIDSHA1:
+		return "sha1"
+	case BuildIDMD5:
+		return "md5"
+	case BuildIDSHA1UUID:
+		return "sha1-uuid"
+	}
+	return fmt.Sprintf("BuildIDAlgorithm(%d)", int(a))
+}
+
+// ParseBuildIDAlgorithm returns the BuildIDAlgorithm with the given name ("sha1", "md5" or "sha1-uuid").
+func ParseBuildIDAlgorithm(name string) (BuildIDAlgorithm, error) {
+	for _, a := range []BuildIDAlgorithm{BuildIDSHA1, BuildIDMD5, BuildIDSHA1UUID} {
+		if a.String() == name {
+			return a, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown build ID algorithm %q", ErrInvalidArgument, name)
+}
+
+// Size returns the length in bytes of the build IDs produced by the algorithm.
+func (a BuildIDAlgorithm) Size() int {
+	if a == BuildIDSHA1 {
+		return sha1.Size
+	}
+	return 16
+}
+
+// sum computes the build ID of data.
+func (a BuildIDAlgorithm) sum(data []byte) []byte {
+	switch a {
+	case BuildIDMD5:
+		sum := md5.Sum(data)
+		return sum[:]
+	case BuildIDSHA1UUID:
+		sum := sha1.Sum(data)
+		id := sum[:16]
+		id[6] = id[6]&0x0f | 0x50 // Version 5
+		id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+		return id
+	}
+	sum := sha1.Sum(data)
+	return sum[:]
+}
+
+// BuildID returns the descriptor of the file's GNU build ID note
+// (NT_GNU_BUILD_ID), usually held by .note.gnu.build-id.
+//
+// Returns:
+//   - The build ID.
+//   - ErrNoteNotFound if the file has no build ID.
+func (f *File) BuildID() ([]byte, error) {
+	n, err := f.buildIDNote()
+	if err != nil {
+		return nil, err
+	}
+	return n.Desc, nil
+}
+
+// SetBuildID replaces the descriptor of the file's GNU build ID note. A file
+// without one gets a non-allocated .note.gnu.build-id section. IDs in
+// allocated sections are rewritten in place; see AddOrReplaceNote for the
+// sizes they may take.
+//
+// Parameters:
+//   - id: The new build ID.
+//
+// Returns:
+//   - An error if the note cannot be stored.
+func (f *File) SetBuildID(id []byte) error {
+	section := ".note.gnu.build-id"
+	n, err := f.buildIDNote()
+	switch {
+	case err == nil && n.Section == "":
+		return fmt.Errorf("%w: build ID note in segment %d is not covered by a section", ErrInvalidArgument, n.Segment)
+	case err == nil:
+		section = n.Section
+	case !errors.Is(err, ErrNoteNotFound):
+		return err
+	}
+	return f.AddOrReplaceNote(section, "GNU", ntGNUBuildID, id)
+}
+
+// RecomputeBuildID sets the file's GNU build ID to the hash of the file that
+// Bytes produces with the ID zeroed, so it reflects every pending edit. The
+// file must not be changed afterwards, other than by another call. A file
+// without a build ID gets one as with SetBuildID. IDs in allocated sections
+// are rewritten in place, so the 16-byte md5 and sha1-uuid IDs cannot replace
+// the 20-byte ID that ld puts in front of other notes, as in most
+// executables, and fail with ErrDoesNotFit.
+//
+// Parameters:
+//   - alg: The algorithm that derives the ID from the file contents.
+//
+// Returns:
+//   - The new build ID.
+//   - An error if the note cannot be stored or the file cannot be serialized.
+func (f *File) RecomputeBuildID(alg BuildIDAlgorithm) ([]byte, error) {
+	if err := f.SetBuildID(make([]byte, alg.Size())); err != nil {
+		return nil, err
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	id := alg.sum(out)
+	// The ID has the same size as the zeroes hashed above, so it does not change the layout
+	if err := f.SetBuildID(id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// buildIDNote returns the first GNU build ID note of the file.
+func (f *File) buildIDNote() (Note, error) {
+	notes, err := f.Notes()
+	if err != nil {
+		return Note{}, err
+	}
+	i := slices.IndexFunc(notes, func(n Note) bool { return n.Name == "GNU" && n.Type == ntGNUBuildID })
+	if i == -1 {
+		return Note{}, fmt.Errorf("%w: NT_GNU_BUILD_ID", ErrNoteNotFound)
+	}
+	return notes[i], nil
+}
+
+// ReadBuildID returns the GNU build ID of the provided ELF data; see File.BuildID.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//
+// Returns:
+//   - The build ID.
+//   - An error if the ELF data is invalid or has no build ID.
+func ReadBuildID(elfData []byte) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	return f.BuildID()
+}
+
+// RecomputeBuildID rewrites the GNU build ID of the ELF data; see File.RecomputeBuildID.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//   - alg: The algorithm that derives the ID from the file contents.
+//
+// Returns:
+//   - A byte slice containing the modified ELF file data.
+//   - An error if the ELF data is invalid or the build ID cannot be stored.
+func RecomputeBuildID(elfData []byte, alg BuildIDAlgorithm) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.RecomputeBuildID(alg); err != nil {
+		return nil, err
+	}
+	return f.Bytes()
+}
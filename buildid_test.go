@@ -0,0 +1,68 @@
+package elfy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"debug/elf"
+	"errors"
+	"testing"
+)
+
+func TestRecomputeBuildID(t *testing.T) {
+	_, f := readFile(t, "tiny64")
+	if _, err := f.AddSection(".comment.elfy", []byte("edited")); err != nil {
+		t.Fatal(err)
+	}
+	id, err := f.RecomputeBuildID(BuildIDSHA1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(out, id)
+	if i == -1 {
+		t.Fatal("build ID not found in output")
+	}
+	zeroed := bytes.Clone(out)
+	clear(zeroed[i : i+len(id)])
+	if sum := sha1.Sum(zeroed); !bytes.Equal(sum[:], id) {
+		t.Errorf("build ID = %x, want %x", id, sum)
+	}
+	if got, err := ReadBuildID(out); err != nil || !bytes.Equal(got, id) {
+		t.Errorf("ReadBuildID = %x, %v", got, err)
+	}
+	again, err := RecomputeBuildID(out, BuildIDSHA1)
+	if err != nil || !bytes.Equal(again, out) {
+		t.Errorf("recomputing the build ID of an unedited file changed it: %v", err)
+	}
+
+	// The allocated note is followed by .note.ABI-tag in its segment
+	if _, err := f.RecomputeBuildID(BuildIDMD5); !errors.Is(err, ErrDoesNotFit) {
+		t.Errorf("shrinking allocated build ID: error = %v, want %v", err, ErrDoesNotFit)
+	}
+}
+
+func TestRecomputeBuildIDWithoutNote(t *testing.T) {
+	data, _ := buildFile(t, testBuilders()["x86_64/rel"])
+	if _, err := ReadBuildID(data); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("error = %v, want %v", err, ErrNoteNotFound)
+	}
+	out, err := RecomputeBuildID(data, BuildIDSHA1UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := parseFile(t, out)
+	checkVerify(t, f)
+	id, err := f.BuildID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 16 || id[6]>>4 != 5 || id[8]>>6 != 2 {
+		t.Errorf("build ID %x lacks the UUID version and variant bits", id)
+	}
+	if s := f.Section(".note.gnu.build-id"); s == nil || s.Type != elf.SHT_NOTE || s.Flags&elf.SHF_ALLOC != 0 {
+		t.Errorf("build ID section = %+v", s)
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/xplshn/elfy"
+
+	"github.com/urfave/cli/v3"
+)
+
+var buildIDCommand = &cli.Command{
+	Name:  "build-id",
+	Usage: "Print the GNU build ID, or set or recompute it",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "generate",
+			Usage: "Recompute the build ID over the file with the ID zeroed: sha1, md5 or sha1-uuid; md5 and sha1-uuid cannot replace the 20-byte ID of most linked executables",
+		},
+		&cli.StringFlag{
+			Name:  "set",
+			Usage: "Set the build ID to these hex-encoded bytes",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "Output ELF file, or - for stdout",
+		},
+		layoutFlag,
+		dryRunFlag,
+		inPlaceFlag,
+		followSymlinksFlag,
+		preserveTimesFlag,
+		forceFlag,
+		strictAttrsFlag,
+		verifyFlag,
+	},
+	Action:    buildID,
+	ArgsUsage: "<input_elf_file|->",
+}
+
+func buildID(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	inputFile := c.Args().First()
+	if c.IsSet("generate") && c.IsSet("set") {
+		return fmt.Errorf("--generate and --set are mutually exclusive")
+	}
+	f, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading ELF file: %w", err)
+	}
+
+	if !c.IsSet("generate") && !c.IsSet("set") {
+		id, err := f.BuildID()
+		if err != nil {
+			return err
+		}
+		if jsonOutput(c) {
+			return printJSON(buildIDJSON{BuildID: hex.EncodeToString(id), Section: buildIDSection(f), Size: len(id)})
+		}
+		fmt.Println(hex.EncodeToString(id))
+		return nil
+	}
+
+	if c.IsSet("set") {
+		id, err := hex.DecodeString(c.String("set"))
+		if err != nil {
+			return fmt.Errorf("%w: invalid build ID: %v", elfy.ErrInvalidArgument, err)
+		}
+		if err := f.SetBuildID(id); err != nil {
+			return fmt.Errorf("error setting build ID: %w", err)
+		}
+	} else {
+		// Hash the file as it will be written
+		if f.Layout, err = elfy.ParseLayoutStrategy(c.String("layout")); err != nil {
+			return err
+		}
+		if err := rebuildID(f, c.String("generate")); err != nil {
+			return err
+		}
+	}
+	return finish(c, f, inputFile, "build-id", []string{buildIDSection(f)}, "Build ID in section %s of %s updated\n")
+}
+
+// rebuildID recomputes the build ID of f with the named algorithm.
+func rebuildID(f *elfy.File, name string) error {
+	alg, err := elfy.ParseBuildIDAlgorithm(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.RecomputeBuildID(alg); err != nil {
+		return fmt.Errorf("error recomputing build ID: %w", err)
+	}
+	return nil
+}
+
+// buildIDSection returns the section holding the build ID of f.
+func buildIDSection(f *elfy.File) string {
+	return noteSection(f, "GNU", 3)
+}
@@ -1,26 +1,140 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"debug/elf"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/xplshn/elfy"
 
 	"github.com/urfave/cli/v3"
 )
 
+var (
+	layoutFlag = &cli.StringFlag{
+		Name:  "layout",
+		Usage: "Placement of moved sections: reuse, append or compact",
+		Value: "reuse",
+	}
+	dryRunFlag = &cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Print the planned section layout instead of writing the output file",
+	}
+	overwriteFlag = &cli.BoolFlag{
+		Name:  "overwrite",
+		Usage: "Overwrite an existing section at its current offset; fails if the data does not fit",
+	}
+	padFlag = &cli.BoolFlag{
+		Name:  "pad",
+		Usage: "With --overwrite, keep the section size and zero-fill the remainder",
+	}
+	typeFlag = &cli.StringFlag{
+		Name:  "type",
+		Usage: "Section type, e.g. progbits, note or nobits (default: progbits for new sections)",
+	}
+	flagsFlag = &cli.StringFlag{
+		Name:  "flags",
+		Usage: "Comma-separated section flags, e.g. alloc,write or none (default: alloc for new sections)",
+	}
+	loadFlag = &cli.BoolFlag{
+		Name:  "load",
+		Usage: "Map the section into memory with a new PT_LOAD segment",
+	}
+	alignFlag = &cli.Uint64Flag{
+		Name:  "align",
+		Usage: "Section alignment in bytes (default: 1 for new sections)",
+	}
+	hexInputFlag = &cli.BoolFlag{
+		Name:  "hex",
+		Usage: "Decode the section data from hex; whitespace is ignored",
+	}
+	inPlaceFlag = &cli.BoolFlag{
+		Name:  "in-place",
+		Usage: "Replace the input file atomically, keeping its permissions, owner and extended attributes",
+	}
+	followSymlinksFlag = &cli.BoolFlag{
+		Name:  "follow-symlinks",
+		Usage: "With --in-place, edit the target of a symlink instead of refusing",
+	}
+	preserveTimesFlag = &cli.BoolFlag{
+		Name:  "preserve-times",
+		Usage: "With --in-place, keep the access and modification times of the input file",
+	}
+	forceFlag = &cli.BoolFlag{
+		Name:  "force",
+		Usage: "With --in-place, replace a file that has other hardlinks; they keep the old contents",
+	}
+	strictAttrsFlag = &cli.BoolFlag{
+		Name:  "strict-attrs",
+		Usage: "With --in-place, fail instead of warning when the owner or extended attributes cannot be preserved",
+	}
+	verifyFlag = &cli.BoolFlag{
+		Name:  "verify",
+		Usage: "Run the structural checks of the verify command on the output and refuse to write it if any fails",
+	}
+	base64InputFlag = &cli.BoolFlag{
+		Name:  "base64",
+		Usage: "Decode the section data from base64; whitespace is ignored",
+	}
+	rebuildIDFlag = &cli.StringFlag{
+		Name:  "rebuild-id",
+		Usage: "Recompute the GNU build ID of the output with this algorithm: sha1, md5 or sha1-uuid; md5 and sha1-uuid cannot replace the 20-byte ID of most linked executables",
+	}
+)
+
 func main() {
 	app := &cli.Command{
 		Name:  "elfy",
 		Usage: "Tool to manipulate ELF sections",
+		Flags: []cli.Flag{formatFlag},
 		Commands: []*cli.Command{
 			{
-				Name:      "list-sections",
-				Usage:     "List all sections in the ELF file",
+				Name:  "list-sections",
+				Usage: "List all sections in the ELF file",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "type",
+						Usage: "Only list sections of this type, e.g. progbits or note",
+					},
+					&cli.StringFlag{
+						Name:  "flag",
+						Usage: "Only list sections that have all of these comma-separated flags, e.g. alloc,exec",
+					},
+					&cli.BoolFlag{
+						Name:  "names",
+						Usage: "Print only section names, one per line",
+					},
+				},
 				Action:    listSections,
-				ArgsUsage: "<input_elf_file>",
+				ArgsUsage: "<input_elf_file|->",
+			},
+			{
+				Name:      "list-segments",
+				Usage:     "List all program headers and the sections each segment covers",
+				Action:    listSegments,
+				ArgsUsage: "<input_elf_file|->",
+			},
+			{
+				Name:  "verify",
+				Usage: "Run structural checks on the ELF file and exit non-zero if any fails",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "strict",
+						Usage: "Treat warnings as failures",
+					},
+				},
+				Action:    verify,
+				ArgsUsage: "<input_elf_file|->",
 			},
 			{
 				Name:  "read-section",
@@ -31,9 +145,33 @@ func main() {
 						Usage:    "Name of the section to read",
 						Required: true,
 					},
+					&cli.BoolFlag{
+						Name:  "raw",
+						Usage: "Write the exact section bytes",
+					},
+					&cli.BoolFlag{
+						Name:  "hex",
+						Usage: "Write an xxd-style hex dump with section-relative offsets",
+					},
+					&cli.BoolFlag{
+						Name:  "base64",
+						Usage: "Write the section bytes base64-encoded",
+					},
+					&cli.StringFlag{
+						Name:  "output",
+						Usage: "Write to this file instead of stdout",
+					},
+					&cli.Uint64Flag{
+						Name:  "offset",
+						Usage: "Start reading at this offset into the section",
+					},
+					&cli.Uint64Flag{
+						Name:  "length",
+						Usage: "Read at most this many bytes (default: to the end of the section)",
+					},
 				},
 				Action:    readSection,
-				ArgsUsage: "<input_elf_file>",
+				ArgsUsage: "<input_elf_file|->",
 			},
 			{
 				Name:  "add-section",
@@ -46,17 +184,34 @@ func main() {
 					},
 					&cli.StringFlag{
 						Name:     "file",
-						Usage:    "File containing the section data",
+						Usage:    "File containing the section data, or - for stdin",
 						Required: true,
 					},
 					&cli.StringFlag{
 						Name:  "output",
-						Usage: "Output ELF file",
+						Usage: "Output ELF file, or - for stdout",
 						Value: "",
 					},
+					hexInputFlag,
+					base64InputFlag,
+					layoutFlag,
+					dryRunFlag,
+					inPlaceFlag,
+					followSymlinksFlag,
+					preserveTimesFlag,
+					forceFlag,
+					strictAttrsFlag,
+					verifyFlag,
+					rebuildIDFlag,
+					overwriteFlag,
+					padFlag,
+					typeFlag,
+					flagsFlag,
+					alignFlag,
+					loadFlag,
 				},
 				Action:    addSectionFromFile,
-				ArgsUsage: "<input_elf_file>",
+				ArgsUsage: "<input_elf_file|->",
 			},
 			{
 				Name:  "add-section-string",
@@ -74,12 +229,29 @@ func main() {
 					},
 					&cli.StringFlag{
 						Name:  "output",
-						Usage: "Output ELF file",
+						Usage: "Output ELF file, or - for stdout",
 						Value: "",
 					},
+					hexInputFlag,
+					base64InputFlag,
+					layoutFlag,
+					dryRunFlag,
+					inPlaceFlag,
+					followSymlinksFlag,
+					preserveTimesFlag,
+					forceFlag,
+					strictAttrsFlag,
+					verifyFlag,
+					rebuildIDFlag,
+					overwriteFlag,
+					padFlag,
+					typeFlag,
+					flagsFlag,
+					alignFlag,
+					loadFlag,
 				},
 				Action:    addSectionFromString,
-				ArgsUsage: "<input_elf_file>",
+				ArgsUsage: "<input_elf_file|->",
 			},
 			{
 				Name:  "remove-section",
@@ -90,22 +262,145 @@ func main() {
 						Usage:    "Name of the section to remove",
 						Required: true,
 					},
+					&cli.BoolFlag{
+						Name:  "cascade",
+						Usage: "Also remove sections that depend on the removed one",
+					},
 					&cli.StringFlag{
 						Name:  "output",
-						Usage: "Output ELF file",
+						Usage: "Output ELF file, or - for stdout",
 						Value: "",
 					},
+					layoutFlag,
+					dryRunFlag,
+					inPlaceFlag,
+					followSymlinksFlag,
+					preserveTimesFlag,
+					forceFlag,
+					strictAttrsFlag,
+					verifyFlag,
+					rebuildIDFlag,
 				},
 				Action:    removeSection,
-				ArgsUsage: "<input_elf_file>",
+				ArgsUsage: "<input_elf_file|->",
 			},
+			{
+				Name:  "rename-section",
+				Usage: "Rename a section, keeping its contents and header fields",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "from",
+						Usage:    "Current name of the section",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "to",
+						Usage:    "New name of the section",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "flags",
+						Usage: "Also set the section flags, e.g. alloc,write or none",
+					},
+					&cli.StringFlag{
+						Name:  "output",
+						Usage: "Output ELF file, or - for stdout",
+						Value: "",
+					},
+					layoutFlag,
+					dryRunFlag,
+					inPlaceFlag,
+					followSymlinksFlag,
+					preserveTimesFlag,
+					forceFlag,
+					strictAttrsFlag,
+					verifyFlag,
+					rebuildIDFlag,
+				},
+				Action:    renameSection,
+				ArgsUsage: "<input_elf_file|->",
+			},
+			{
+				Name:  "apply",
+				Usage: "Apply a JSON manifest of section operations in a single pass",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "manifest",
+						Usage:    "JSON manifest listing add, replace, remove, rename and set-flags operations, or - for stdin",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "output",
+						Usage: "Output ELF file, or - for stdout",
+						Value: "",
+					},
+					layoutFlag,
+					dryRunFlag,
+					inPlaceFlag,
+					followSymlinksFlag,
+					preserveTimesFlag,
+					forceFlag,
+					strictAttrsFlag,
+					verifyFlag,
+					rebuildIDFlag,
+				},
+				Action:    applyManifest,
+				ArgsUsage: "<input_elf_file|->",
+			},
+			{
+				Name:  "embed",
+				Usage: "Turn a data file into a relocatable object with _binary_<name>_start, _end and _size symbols",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "arch",
+						Usage:    "Target architecture, e.g. x86_64, aarch64, riscv64, mips64el or a GOARCH name",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "Stem of the symbol names (default: the data file name)",
+					},
+					&cli.StringFlag{
+						Name:  "section",
+						Usage: "Section to hold the data (default: .rodata, or .data with --writable)",
+					},
+					&cli.Uint64Flag{
+						Name:  "align",
+						Usage: "Section alignment in bytes",
+						Value: 1,
+					},
+					&cli.BoolFlag{
+						Name:  "writable",
+						Usage: "Place the data in a writable section",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Output object file, or - for stdout (default: the data file name with a .o suffix)",
+					},
+				},
+				Action:    embed,
+				ArgsUsage: "<data_file|->",
+			},
+			notesCommand,
+			buildIDCommand,
+			symbolsCommand,
+			editSymbolsCommand,
 		},
 	}
 
 	err := app.Run(context.Background(), os.Args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		var verifyErr *verifyError
+		switch {
+		case jsonOutput(app) && errors.As(err, &verifyErr) && verifyErr.reported:
+			// The verify report on stdout already holds the problems
+		case jsonOutput(app):
+			printError(err)
+		default:
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -114,16 +409,184 @@ func listSections(ctx context.Context, c *cli.Command) error {
 		return fmt.Errorf("missing input ELF file")
 	}
 	inputFile := c.Args().First()
-	elfData, err := os.ReadFile(inputFile)
+	elfData, err := readInput(inputFile)
 	if err != nil {
-		return fmt.Errorf("error reading file: %v", err)
+		return fmt.Errorf("error reading file: %w", err)
 	}
-	sections, err := elfy.ListSections(elfData)
+	sections, err := elfy.Sections(elfData)
 	if err != nil {
 		return err
 	}
+	sections, err = filterSections(c, sections)
+	if err != nil {
+		return err
+	}
+	if jsonOutput(c) {
+		out := make([]sectionJSON, 0, len(sections))
+		for _, sec := range sections {
+			out = append(out, newSectionJSON(sec))
+		}
+		return printJSON(map[string]any{"sections": out})
+	}
+	if c.Bool("names") {
+		for _, sec := range sections {
+			fmt.Println(sec.Name)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "[Nr]\tName\tType\tAddress\tOffset\tSize\tES\tFlg\tLk\tInf\tAl")
 	for _, sec := range sections {
-		fmt.Println(sec)
+		fmt.Fprintf(w, "[%d]\t%s\t%s\t%016x\t%08x\t%08x\t%02x\t%s\t%d\t%d\t%d\n",
+			sec.Index, sec.Name, strings.TrimPrefix(sec.Type.String(), "SHT_"), sec.Addr, sec.Offset,
+			sec.Size, sec.Entsize, sectionFlags(sec.Flags), sec.Link, sec.Info, sec.Addralign)
+	}
+	return w.Flush()
+}
+
+// filterSections keeps the sections matching the --type and --flag options.
+func filterSections(c *cli.Command, sections []elfy.SectionInfo) ([]elfy.SectionInfo, error) {
+	if c.IsSet("type") {
+		typ, err := elfy.ParseSectionType(c.String("type"))
+		if err != nil {
+			return nil, err
+		}
+		sections = slices.DeleteFunc(sections, func(s elfy.SectionInfo) bool { return s.Type != typ })
+	}
+	if c.IsSet("flag") {
+		flags, err := elfy.ParseSectionFlags(c.String("flag"))
+		if err != nil {
+			return nil, err
+		}
+		sections = slices.DeleteFunc(sections, func(s elfy.SectionInfo) bool { return s.Flags&flags != flags })
+	}
+	return sections, nil
+}
+
+// sectionFlags renders section flags as readelf's key letters, e.g. "AX".
+func sectionFlags(flags elf.SectionFlag) string {
+	keys := []struct {
+		flag elf.SectionFlag
+		key  byte
+	}{
+		{elf.SHF_WRITE, 'W'}, {elf.SHF_ALLOC, 'A'}, {elf.SHF_EXECINSTR, 'X'},
+		{elf.SHF_MERGE, 'M'}, {elf.SHF_STRINGS, 'S'}, {elf.SHF_INFO_LINK, 'I'},
+		{elf.SHF_LINK_ORDER, 'L'}, {elf.SHF_OS_NONCONFORMING, 'O'}, {elf.SHF_GROUP, 'G'},
+		{elf.SHF_TLS, 'T'}, {elf.SHF_COMPRESSED, 'C'},
+	}
+	var b []byte
+	for _, k := range keys {
+		if flags&k.flag != 0 {
+			b = append(b, k.key)
+			flags &^= k.flag
+		}
+	}
+	if flags != 0 {
+		b = append(b, 'x')
+	}
+	return string(b)
+}
+
+func listSegments(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	f, err := openInput(c.Args().First())
+	if err != nil {
+		return err
+	}
+	if jsonOutput(c) {
+		out := make([]segmentJSON, 0, len(f.Segments))
+		for i, seg := range f.Segments {
+			names := []string{}
+			for _, s := range f.SegmentSections(seg) {
+				names = append(names, s.Name)
+			}
+			out = append(out, segmentJSON{Index: i, progJSON: newProgJSON(seg.ProgHeader), Sections: names})
+		}
+		return printJSON(map[string]any{"segments": out})
+	}
+	if len(f.Segments) == 0 {
+		fmt.Println("There are no program headers in this file.")
+		return nil
+	}
+	fmt.Printf("%-14s %-18s %-18s %-18s %-18s %-18s %-5s %s\n",
+		"Type", "Offset", "VirtAddr", "PhysAddr", "FileSiz", "MemSiz", "Flags", "Align")
+	for _, seg := range f.Segments {
+		fmt.Printf("%-14s 0x%016x 0x%016x 0x%016x 0x%016x 0x%016x %-5s 0x%x\n",
+			strings.TrimPrefix(seg.Type.String(), "PT_"), seg.Off, seg.Vaddr, seg.Paddr,
+			seg.Filesz, seg.Memsz, segmentFlags(seg.Flags), seg.Align)
+	}
+	fmt.Println()
+	fmt.Println("Section to Segment mapping:")
+	for i, seg := range f.Segments {
+		var names []string
+		for _, s := range f.SegmentSections(seg) {
+			names = append(names, s.Name)
+		}
+		fmt.Printf("  %02d     %s\n", i, strings.Join(names, " "))
+	}
+	return nil
+}
+
+// segmentFlags renders program header flags the way readelf does, e.g. "R E".
+func segmentFlags(flags elf.ProgFlag) string {
+	b := []byte("   ")
+	if flags&elf.PF_R != 0 {
+		b[0] = 'R'
+	}
+	if flags&elf.PF_W != 0 {
+		b[1] = 'W'
+	}
+	if flags&elf.PF_X != 0 {
+		b[2] = 'E'
+	}
+	return string(b)
+}
+
+func verify(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	inputFile := c.Args().First()
+	elfData, err := readInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading file: %w", err)
+	}
+	problems, err := elfy.Verify(elfData)
+	if err != nil {
+		return err
+	}
+	errs, warnings := 0, 0
+	for _, p := range problems {
+		if p.Severity == elfy.SeverityError {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+	if jsonOutput(c) {
+		out := verifyJSON{File: inputFile, Errors: errs, Warnings: warnings, Problems: []problemJSON{}}
+		for _, p := range problems {
+			out.Problems = append(out.Problems, problemJSON{p.Severity.String(), p.Check, p.Location, p.Message})
+		}
+		if err := printJSON(out); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+		fmt.Printf("%s: %d errors, %d warnings\n", inputFile, errs, warnings)
+	}
+	var failed []elfy.Problem
+	for _, p := range problems {
+		if p.Severity == elfy.SeverityError || c.Bool("strict") {
+			failed = append(failed, p)
+		}
+	}
+	if len(failed) > 0 {
+		return &verifyError{file: inputFile, problems: failed, reported: true}
 	}
 	return nil
 }
@@ -134,16 +597,87 @@ func readSection(ctx context.Context, c *cli.Command) error {
 	}
 	inputFile := c.Args().First()
 	sectionName := c.String("name")
-	elfData, err := os.ReadFile(inputFile)
+	modes := 0
+	for _, mode := range []string{"raw", "hex", "base64"} {
+		if c.Bool(mode) {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return fmt.Errorf("--raw, --hex and --base64 are mutually exclusive")
+	}
+	elfData, err := readInput(inputFile)
 	if err != nil {
-		return fmt.Errorf("error reading file: %v", err)
+		return fmt.Errorf("error reading file: %w", err)
 	}
 	data, err := elfy.ReadSection(elfData, sectionName)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Content of section %s:\n%s\n", sectionName, string(data))
-	return nil
+	offset := c.Uint64("offset")
+	if offset > uint64(len(data)) {
+		return fmt.Errorf("offset %d is past the end of section %s (%d bytes)", offset, sectionName, len(data))
+	}
+	data = data[offset:]
+	if c.IsSet("length") {
+		data = data[:min(c.Uint64("length"), uint64(len(data)))]
+	}
+
+	var out bytes.Buffer
+	switch {
+	case jsonOutput(c):
+		res := newSectionDataJSON(sectionName, data)
+		res.Offset = offset
+		if err := encodeJSON(&out, res); err != nil {
+			return err
+		}
+	case c.Bool("raw"):
+		out.Write(data)
+	case c.Bool("hex"):
+		hexDump(&out, data, offset)
+	case c.Bool("base64"):
+		out.WriteString(base64.StdEncoding.EncodeToString(data))
+		out.WriteByte('\n')
+	default:
+		fmt.Fprintf(&out, "Content of section %s:\n%s\n", sectionName, string(data))
+	}
+	if outputFile := c.String("output"); outputFile != "" {
+		if err := os.WriteFile(outputFile, out.Bytes(), 0644); err != nil {
+			return fmt.Errorf("error writing output file: %w", err)
+		}
+		return nil
+	}
+	_, err = os.Stdout.Write(out.Bytes())
+	return err
+}
+
+// hexDump writes data in the format of xxd, numbering lines from base.
+func hexDump(w io.Writer, data []byte, base uint64) {
+	for len(data) > 0 {
+		line := data[:min(16, len(data))]
+		data = data[len(line):]
+		fmt.Fprintf(w, "%08x: ", base)
+		for i := range 16 {
+			switch {
+			case i < len(line):
+				fmt.Fprintf(w, "%02x", line[i])
+			default:
+				fmt.Fprint(w, "  ")
+			}
+			if i%2 == 1 {
+				fmt.Fprint(w, " ")
+			}
+		}
+		fmt.Fprint(w, " ")
+		for _, b := range line {
+			if b < 0x20 || b > 0x7e {
+				b = '.'
+			}
+			fmt.Fprintf(w, "%c", b)
+		}
+		fmt.Fprintln(w)
+		base += uint64(len(line))
+	}
 }
 
 func addSectionFromFile(ctx context.Context, c *cli.Command) error {
@@ -153,28 +687,25 @@ func addSectionFromFile(ctx context.Context, c *cli.Command) error {
 	inputFile := c.Args().First()
 	sectionName := c.String("name")
 	filePath := c.String("file")
-	outputFile := c.String("output")
-	if outputFile == "" {
-		outputFile = filepath.Base(inputFile) + ".modified"
+	if filePath == "-" && inputFile == "-" {
+		return fmt.Errorf("the section data and the input ELF file cannot both be read from stdin")
 	}
-	sectionData, err := os.ReadFile(filePath)
+	sectionData, err := readInput(filePath)
 	if err != nil {
-		return fmt.Errorf("error reading section data file: %v", err)
+		return fmt.Errorf("error reading section data file: %w", err)
 	}
-	elfData, err := os.ReadFile(inputFile)
+	sectionData, err = decodeSectionData(c, sectionData)
 	if err != nil {
-		return fmt.Errorf("error reading ELF file: %v", err)
+		return err
 	}
-	newElfData, err := elfy.AddOrReplaceSection(elfData, sectionName, sectionData)
+	f, err := openInput(inputFile)
 	if err != nil {
-		return fmt.Errorf("error adding or replacing section: %v", err)
+		return fmt.Errorf("error reading ELF file: %w", err)
 	}
-	err = os.WriteFile(outputFile, newElfData, 0644)
-	if err != nil {
-		return fmt.Errorf("error writing output file: %v", err)
+	if err := addOrReplace(c, f, sectionName, sectionData); err != nil {
+		return fmt.Errorf("error adding or replacing section: %w", err)
 	}
-	fmt.Printf("Section %s added or replaced in %s\n", sectionName, outputFile)
-	return nil
+	return finish(c, f, inputFile, "add", []string{sectionName}, "Section %s added or replaced in %s\n")
 }
 
 func addSectionFromString(ctx context.Context, c *cli.Command) error {
@@ -184,25 +715,18 @@ func addSectionFromString(ctx context.Context, c *cli.Command) error {
 	inputFile := c.Args().First()
 	sectionName := c.String("name")
 	content := c.String("content")
-	outputFile := c.String("output")
-	if outputFile == "" {
-		outputFile = filepath.Base(inputFile) + ".modified"
-	}
-	sectionData := []byte(content)
-	elfData, err := os.ReadFile(inputFile)
+	sectionData, err := decodeSectionData(c, []byte(content))
 	if err != nil {
-		return fmt.Errorf("error reading ELF file: %v", err)
+		return err
 	}
-	newElfData, err := elfy.AddOrReplaceSection(elfData, sectionName, sectionData)
+	f, err := openInput(inputFile)
 	if err != nil {
-		return fmt.Errorf("error adding or replacing section: %v", err)
+		return fmt.Errorf("error reading ELF file: %w", err)
 	}
-	err = os.WriteFile(outputFile, newElfData, 0644)
-	if err != nil {
-		return fmt.Errorf("error writing output file: %v", err)
+	if err := addOrReplace(c, f, sectionName, sectionData); err != nil {
+		return fmt.Errorf("error adding or replacing section: %w", err)
 	}
-	fmt.Printf("Section %s added or replaced in %s\n", sectionName, outputFile)
-	return nil
+	return finish(c, f, inputFile, "add", []string{sectionName}, "Section %s added or replaced in %s\n")
 }
 
 func removeSection(ctx context.Context, c *cli.Command) error {
@@ -211,22 +735,361 @@ func removeSection(ctx context.Context, c *cli.Command) error {
 	}
 	inputFile := c.Args().First()
 	sectionName := c.String("name")
+	f, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading ELF file: %w", err)
+	}
+	removed := []string{sectionName}
+	if c.Bool("cascade") {
+		removed, err = f.RemoveSectionCascade(sectionName)
+	} else {
+		err = f.RemoveSection(sectionName)
+	}
+	if err != nil {
+		return fmt.Errorf("error removing section: %w", err)
+	}
+	return finish(c, f, inputFile, "remove", removed, "Section %s removed from %s\n")
+}
+
+func renameSection(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	inputFile := c.Args().First()
+	from, to := c.String("from"), c.String("to")
+	var opts elfy.SectionOptions
+	if c.IsSet("flags") {
+		flags, err := elfy.ParseSectionFlags(c.String("flags"))
+		if err != nil {
+			return err
+		}
+		opts.Flags = &flags
+	}
+	f, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading ELF file: %w", err)
+	}
+	if err := f.RenameSectionWithOptions(from, to, opts); err != nil {
+		return fmt.Errorf("error renaming section: %w", err)
+	}
+	return finish(c, f, inputFile, "rename", []string{to}, "Section %s renamed to %s in %s\n", from)
+}
+
+func applyManifest(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	inputFile := c.Args().First()
+	manifestFile := c.String("manifest")
+	if manifestFile == "-" && inputFile == "-" {
+		return fmt.Errorf("the manifest and the input ELF file cannot both be read from stdin")
+	}
+	manifest, err := readInput(manifestFile)
+	if err != nil {
+		return fmt.Errorf("error reading manifest: %w", err)
+	}
+	ops, err := elfy.ParseManifest(manifest, filepath.Dir(manifestFile))
+	if err != nil {
+		return err
+	}
+	f, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading ELF file: %w", err)
+	}
+	if err := f.Apply(ops); err != nil {
+		return fmt.Errorf("error applying manifest: %w", err)
+	}
+	var names []string
+	for _, op := range ops {
+		if !slices.Contains(names, op.Name) {
+			names = append(names, op.Name)
+		}
+	}
+	return finish(c, f, inputFile, "apply", names, "Section %s updated in %s\n")
+}
+
+func embed(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing data file")
+	}
+	inputFile := c.Args().First()
+	arch, err := elfy.LookupArch(c.String("arch"))
+	if err != nil {
+		return err
+	}
+	name := c.String("name")
+	if name == "" {
+		if inputFile == "-" {
+			return fmt.Errorf("--name is required when reading the data from stdin")
+		}
+		name = filepath.Base(inputFile)
+	}
+	data, err := readInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading data file: %w", err)
+	}
+	opts := elfy.EmbedOptions{
+		Arch:     arch,
+		Name:     name,
+		Section:  c.String("section"),
+		Align:    c.Uint64("align"),
+		Writable: c.Bool("writable"),
+	}
+	obj, err := elfy.Embed(data, opts)
+	if err != nil {
+		return fmt.Errorf("error embedding data: %w", err)
+	}
 	outputFile := c.String("output")
 	if outputFile == "" {
-		outputFile = filepath.Base(inputFile) + ".modified"
+		outputFile = strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile)) + ".o"
+		if inputFile == "-" {
+			outputFile = "-"
+		}
+	}
+	report := os.Stdout
+	if outputFile == "-" {
+		report = os.Stderr
+		if _, err := os.Stdout.Write(obj); err != nil {
+			return fmt.Errorf("error writing output: %w", err)
+		}
+	} else if err := os.WriteFile(outputFile, obj, 0644); err != nil {
+		return fmt.Errorf("error writing output file: %w", err)
+	}
+
+	start, end, size := elfy.EmbedSymbols(name)
+	section := opts.SectionName()
+	if jsonOutput(c) {
+		res := embedJSON{Arch: arch.Name, Section: section, Size: len(data), Output: outputFile, Symbols: []string{start, end, size}}
+		return encodeJSON(report, res)
 	}
-	elfData, err := os.ReadFile(inputFile)
+	fmt.Fprintf(report, "Embedded %d bytes into section %s of %s as %s, %s and %s\n", len(data), section, outputFile, start, end, size)
+	return nil
+}
+
+// addOrReplace adds or replaces a section, overwriting it at its current
+// offset when --overwrite is set.
+func addOrReplace(c *cli.Command, f *elfy.File, sectionName string, sectionData []byte) error {
+	opts, err := sectionOptions(c)
 	if err != nil {
-		return fmt.Errorf("error reading ELF file: %v", err)
+		return err
+	}
+	if c.Bool("overwrite") {
+		if err := f.ReplaceSectionInPlace(sectionName, sectionData, c.Bool("pad")); err != nil {
+			return err
+		}
+		return f.UpdateSection(sectionName, opts)
+	}
+	_, err = f.AddOrReplaceSectionWithOptions(sectionName, sectionData, opts)
+	return err
+}
+
+// sectionOptions builds the header attributes selected by --type, --flags, --align and --load.
+func sectionOptions(c *cli.Command) (elfy.SectionOptions, error) {
+	var opts elfy.SectionOptions
+	if c.IsSet("type") {
+		typ, err := elfy.ParseSectionType(c.String("type"))
+		if err != nil {
+			return opts, err
+		}
+		opts.Type = &typ
+	}
+	if c.IsSet("flags") {
+		flags, err := elfy.ParseSectionFlags(c.String("flags"))
+		if err != nil {
+			return opts, err
+		}
+		opts.Flags = &flags
+	}
+	if c.IsSet("align") {
+		align := c.Uint64("align")
+		opts.Addralign = &align
+	}
+	opts.Load = c.Bool("load")
+	return opts, nil
+}
+
+// finish writes the edited file, or plans it with --dry-run, and reports the
+// affected sections using the format message, which receives args followed by
+// a section name and the output file.
+func finish(c *cli.Command, f *elfy.File, inputFile, operation string, sections []string, message string, args ...any) error {
+	outputFile, plan, err := writeOutput(c, f, inputFile)
+	if err != nil {
+		return err
+	}
+	// Keep stdout clean when the ELF file itself is written there
+	report := os.Stdout
+	if outputFile == "-" {
+		report = os.Stderr
+	}
+	var buildID string
+	if c.String("rebuild-id") != "" || operation == "build-id" {
+		if id, err := f.BuildID(); err == nil {
+			buildID = hex.EncodeToString(id)
+		}
+	}
+	if jsonOutput(c) {
+		res := resultJSON{Operation: operation, Sections: sections, Output: outputFile, BuildID: buildID}
+		if plan != nil {
+			res.DryRun = true
+			res.Plan = newPlanJSON(plan)
+		}
+		return encodeJSON(report, res)
+	}
+	if plan != nil {
+		fmt.Fprint(report, plan)
+		return nil
+	}
+	for _, name := range sections {
+		fmt.Fprintf(report, message, append(slices.Clone(args), name, outputFile)...)
+	}
+	if buildID != "" {
+		fmt.Fprintf(report, "Build ID: %s\n", buildID)
+	}
+	return nil
+}
+
+// writeOutput serializes f to the file named by --output, defaulting to the
+// input's base name with a ".modified" suffix, and returns the output name.
+// With --dry-run it writes nothing and returns the layout plan instead.
+func writeOutput(c *cli.Command, f *elfy.File, inputFile string) (string, *elfy.LayoutPlan, error) {
+	layout, err := elfy.ParseLayoutStrategy(c.String("layout"))
+	if err != nil {
+		return "", nil, err
+	}
+	f.Layout = layout
+	if alg := c.String("rebuild-id"); alg != "" {
+		if err := rebuildID(f, alg); err != nil {
+			return "", nil, err
+		}
+	}
+	if c.Bool("dry-run") {
+		plan, err := f.PlanLayout()
+		if err != nil {
+			return "", nil, fmt.Errorf("error planning layout: %w", err)
+		}
+		return "", plan, nil
 	}
-	newElfData, err := elfy.RemoveSection(elfData, sectionName)
+	if c.Bool("in-place") && (c.String("output") != "" || inputFile == "-") {
+		return "", nil, fmt.Errorf("--in-place cannot be combined with --output or stdin input")
+	}
+	newElfData, err := f.Bytes()
 	if err != nil {
-		return fmt.Errorf("error removing section: %v", err)
+		return "", nil, err
+	}
+	if c.Bool("verify") {
+		if err := verifyOutput(newElfData); err != nil {
+			return "", nil, err
+		}
+	}
+	if c.Bool("in-place") {
+		if err := writeInPlace(inputFile, newElfData, inPlaceOptions{
+			followSymlinks: c.Bool("follow-symlinks"),
+			preserveTimes:  c.Bool("preserve-times"),
+			force:          c.Bool("force"),
+			strictAttrs:    c.Bool("strict-attrs"),
+		}); err != nil {
+			return "", nil, err
+		}
+		return inputFile, nil, nil
+	}
+	outputFile := c.String("output")
+	if outputFile == "" {
+		outputFile = filepath.Base(inputFile) + ".modified"
+		if inputFile == "-" {
+			outputFile = "-"
+		}
+	}
+	if outputFile == "-" {
+		if _, err := os.Stdout.Write(newElfData); err != nil {
+			return "", nil, fmt.Errorf("error writing output: %w", err)
+		}
+		return outputFile, nil, nil
+	}
+	if err := os.WriteFile(outputFile, newElfData, 0644); err != nil {
+		return "", nil, fmt.Errorf("error writing output file: %w", err)
 	}
-	err = os.WriteFile(outputFile, newElfData, 0644)
+	return outputFile, nil, nil
+}
+
+// verifyOutput refuses edited ELF data that fails any structural check.
+func verifyOutput(elfData []byte) error {
+	problems, err := elfy.Verify(elfData)
 	if err != nil {
-		return fmt.Errorf("error writing output file: %v", err)
+		return fmt.Errorf("output failed verification: %w", err)
+	}
+	var errs []elfy.Problem
+	for _, p := range problems {
+		if p.Severity == elfy.SeverityError {
+			errs = append(errs, p)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("nothing written: %w", &verifyError{file: "output", problems: errs})
 	}
-	fmt.Printf("Section %s removed from %s\n", sectionName, outputFile)
 	return nil
 }
+
+// verifyError reports the problems that made a file fail verification.
+type verifyError struct {
+	file     string
+	problems []elfy.Problem // The problems counted as failures
+	reported bool           // Whether the problems were already printed
+}
+
+func (e *verifyError) Error() string {
+	msg := fmt.Sprintf("%s failed verification with %d problem(s)", e.file, len(e.problems))
+	if e.reported {
+		return msg
+	}
+	for _, p := range e.problems {
+		msg += "\n  " + p.String()
+	}
+	return msg
+}
+
+// readInput reads the named file, or stdin when name is "-".
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// openInput parses the named ELF file, or stdin when name is "-".
+func openInput(name string) (*elfy.File, error) {
+	if name != "-" {
+		return elfy.Open(name)
+	}
+	data, err := readInput(name)
+	if err != nil {
+		return nil, fmt.Errorf("error reading stdin: %w", err)
+	}
+	return elfy.Parse(data)
+}
+
+// decodeSectionData decodes section data given with --hex or --base64.
+func decodeSectionData(c *cli.Command, data []byte) ([]byte, error) {
+	if c.Bool("hex") && c.Bool("base64") {
+		return nil, fmt.Errorf("--hex and --base64 are mutually exclusive")
+	}
+	switch {
+	case c.Bool("hex"):
+		decoded, err := hex.DecodeString(stripSpace(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding hex section data: %w", err)
+		}
+		return decoded, nil
+	case c.Bool("base64"):
+		decoded, err := base64.StdEncoding.DecodeString(stripSpace(data))
+		if err != nil {
+			return nil, fmt.Errorf("error decoding base64 section data: %w", err)
+		}
+		return decoded, nil
+	}
+	return data, nil
+}
+
+// stripSpace returns text data with all whitespace removed.
+func stripSpace(data []byte) string {
+	return strings.Join(strings.Fields(string(data)), "")
+}
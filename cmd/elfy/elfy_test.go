@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestMain runs elfy itself instead of the tests when ELFY_TEST_MAIN is set,
+// so that runElfy can check the output and exit status of a whole run.
+func TestMain(m *testing.M) {
+	if os.Getenv("ELFY_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// result holds the output and exit status of an elfy run.
+type result struct {
+	stdout, stderr []byte
+	code           int
+}
+
+// runElfy runs elfy with args in dir, feeding it stdin.
+func runElfy(t *testing.T, dir string, stdin []byte, args ...string) result {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "ELFY_TEST_MAIN=1")
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return result{stdout.Bytes(), stderr.Bytes(), cmd.ProcessState.ExitCode()}
+}
+
+// runOK runs elfy and fails the test unless it exits successfully.
+func runOK(t *testing.T, dir string, stdin []byte, args ...string) result {
+	t.Helper()
+	r := runElfy(t, dir, stdin, args...)
+	if r.code != 0 {
+		t.Fatalf("elfy %q exited with %d: %s", args, r.code, r.stderr)
+	}
+	return r
+}
+
+// copyTestdata copies the named file of the library's testdata into a new
+// temporary directory and returns the directory.
+func copyTestdata(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// openELF parses the named file in dir with debug/elf.
+func openELF(t *testing.T, dir, name string) *elf.File {
+	t.Helper()
+	ef, err := elf.Open(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ef.Close() })
+	return ef
+}
+
+func TestSectionOptionFlags(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	runOK(t, dir, nil, "add-section-string", "--name", ".opts", "--content", "abcd",
+		"--type", "SHT_NOTE", "--flags", "alloc,shf_write", "--align", "16", "--output", "out", "tiny64")
+	runOK(t, dir, nil, "add-section-string", "--name", ".num", "--content", "x",
+		"--type", "0x70000001", "--flags", "0x10000000,exec", "--output", "out2", "out")
+	ef := openELF(t, dir, "out2")
+	if s := ef.Section(".opts"); s.Type != elf.SHT_NOTE || s.Flags != elf.SHF_ALLOC|elf.SHF_WRITE || s.Addralign != 16 || s.Addr%16 != 0 {
+		t.Errorf(".opts header = %+v", s.SectionHeader)
+	}
+	if s := ef.Section(".num"); s.Type != elf.SectionType(0x70000001) || s.Flags != elf.SHF_EXECINSTR|elf.SectionFlag(0x10000000) || s.Addralign != 1 {
+		t.Errorf(".num header = %+v", s.SectionHeader)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"--type", "bogus"}, exitInvalidArg},
+		{[]string{"--flags", "alloc,nope"}, exitInvalidArg},
+		{[]string{"--align", "3"}, exitInvalidArg},
+		{[]string{"--align", "-1"}, exitFailure},
+	} {
+		args := append([]string{"add-section-string", "--name", ".bad", "--content", "x", "--output", "bad"}, append(tt.args, "tiny64")...)
+		if r := runElfy(t, dir, nil, args...); r.code != tt.code {
+			t.Errorf("elfy %q exited with %d, want %d: %s", args, r.code, tt.code, r.stderr)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "bad")); err == nil {
+			t.Errorf("elfy %q wrote output", args)
+		}
+	}
+}
+
+func TestListSections(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	ef := openELF(t, dir, "tiny64")
+
+	// Every row of the table matches the header read by debug/elf
+	r := runOK(t, dir, nil, "list-sections", "tiny64")
+	rows := strings.Split(strings.TrimSpace(string(r.stdout)), "\n")
+	if len(rows) != len(ef.Sections)+1 {
+		t.Fatalf("%d rows, want a header and %d sections", len(rows), len(ef.Sections))
+	}
+	for i, s := range ef.Sections[1:] {
+		want := []string{
+			fmt.Sprintf("[%d]", i+1), s.Name, strings.TrimPrefix(s.Type.String(), "SHT_"),
+			fmt.Sprintf("%016x", s.Addr), fmt.Sprintf("%08x", s.Offset), fmt.Sprintf("%08x", s.Size),
+			fmt.Sprintf("%02x", s.Entsize), sectionFlags(s.Flags),
+			fmt.Sprint(s.Link), fmt.Sprint(s.Info), fmt.Sprint(s.Addralign),
+		}
+		// Flags can be empty, shifting the remaining columns
+		want = slices.DeleteFunc(want, func(s string) bool { return s == "" })
+		if got := strings.Fields(rows[i+2]); !slices.Equal(got, want) {
+			t.Errorf("row %d = %q, want %q", i+1, got, want)
+		}
+	}
+
+	filter := func(typ elf.SectionType, flags elf.SectionFlag) []string {
+		var names []string
+		for _, s := range ef.Sections {
+			if (typ == elf.SHT_NULL || s.Type == typ) && s.Flags&flags == flags {
+				names = append(names, s.Name)
+			}
+		}
+		return names
+	}
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, filter(elf.SHT_NULL, 0)},
+		{[]string{"--type", "progbits"}, filter(elf.SHT_PROGBITS, 0)},
+		{[]string{"--type", "SHT_NOTE"}, filter(elf.SHT_NOTE, 0)},
+		{[]string{"--flag", "alloc"}, filter(elf.SHT_NULL, elf.SHF_ALLOC)},
+		{[]string{"--flag", "alloc,exec"}, filter(elf.SHT_NULL, elf.SHF_ALLOC|elf.SHF_EXECINSTR)},
+		{[]string{"--type", "progbits", "--flag", "write"}, filter(elf.SHT_PROGBITS, elf.SHF_WRITE)},
+		{[]string{"--type", "nobits", "--flag", "exec"}, nil},
+	}
+	for _, tt := range tests {
+		args := append(append([]string{"list-sections", "--names"}, tt.args...), "tiny64")
+		r := runOK(t, dir, nil, args...)
+		got := strings.Fields(string(r.stdout))
+		// The null section has an empty name
+		want := slices.DeleteFunc(slices.Clone(tt.want), func(s string) bool { return s == "" })
+		if !slices.Equal(got, want) {
+			t.Errorf("elfy %q = %q, want %q", args, got, want)
+		}
+		if len(want) == 0 && tt.args != nil && len(r.stdout) != 0 {
+			t.Errorf("elfy %q printed %q", args, r.stdout)
+		}
+	}
+	for _, args := range [][]string{{"--type", "bogus"}, {"--flag", "alloc,bogus"}} {
+		args = append(append([]string{"list-sections"}, args...), "tiny64")
+		if r := runElfy(t, dir, nil, args...); r.code != exitInvalidArg {
+			t.Errorf("elfy %q exited with %d, want %d", args, r.code, exitInvalidArg)
+		}
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	var out bytes.Buffer
+	hexDump(&out, []byte("0123456789abcdef\x00\x7fZ"), 0x10)
+	want := "00000010: 3031 3233 3435 3637 3839 6162 6364 6566  0123456789abcdef\n" +
+		"00000020: 007f 5a                                  ..Z\n"
+	if out.String() != want {
+		t.Errorf("hexDump =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestReadSection(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	comment, err := openELF(t, dir, "tiny64").Section(".comment").Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	input, err := os.ReadFile(filepath.Join(dir, "tiny64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump bytes.Buffer
+	hexDump(&dump, comment[4:24], 4)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--raw"}, string(comment)},
+		{[]string{"--raw", "--offset", "2", "--length", "5"}, string(comment[2:7])},
+		{[]string{"--raw", "--offset", "3", "--length", "1000"}, string(comment[3:])},
+		{[]string{"--raw", "--offset", fmt.Sprint(len(comment))}, ""},
+		{[]string{"--base64"}, base64.StdEncoding.EncodeToString(comment) + "\n"},
+		{[]string{"--base64", "--length", "4"}, base64.StdEncoding.EncodeToString(comment[:4]) + "\n"},
+		{[]string{"--hex", "--offset", "4", "--length", "20"}, dump.String()},
+		{nil, "Content of section .comment:\n" + string(comment) + "\n"},
+	}
+	for _, tt := range tests {
+		args := append(append([]string{"read-section", "--name", ".comment"}, tt.args...), "tiny64")
+		if r := runOK(t, dir, nil, args...); string(r.stdout) != tt.want {
+			t.Errorf("elfy %q = %q, want %q", args, r.stdout, tt.want)
+		}
+		// The same bytes come out when reading stdin or writing a file
+		stdin := append(slices.Clone(args[:len(args)-1]), "-")
+		if r := runOK(t, dir, input, stdin...); string(r.stdout) != tt.want {
+			t.Errorf("elfy %q = %q, want %q", stdin, r.stdout, tt.want)
+		}
+		file := append(slices.Clone(args[:len(args)-1]), "--output", "section.out", "tiny64")
+		if r := runOK(t, dir, nil, file...); len(r.stdout) != 0 {
+			t.Errorf("elfy %q printed %q", file, r.stdout)
+		}
+		if got, err := os.ReadFile(filepath.Join(dir, "section.out")); err != nil || string(got) != tt.want {
+			t.Errorf("elfy %q wrote %q, %v", file, got, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"--name", ".comment", "--raw", "--hex"}, exitFailure},
+		{[]string{"--name", ".comment", "--hex", "--base64"}, exitFailure},
+		{[]string{"--name", ".comment", "--offset", fmt.Sprint(len(comment) + 1)}, exitFailure},
+		{[]string{"--name", ".nope"}, exitNotFound},
+	} {
+		args := append(append([]string{"read-section"}, tt.args...), "tiny64")
+		if r := runElfy(t, dir, nil, args...); r.code != tt.code || len(r.stdout) != 0 {
+			t.Errorf("elfy %q exited with %d and printed %q, want %d", args, r.code, r.stdout, tt.code)
+		}
+	}
+}
+
+func TestAddSectionInput(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	input, err := os.ReadFile(filepath.Join(dir, "tiny64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte{0xde, 0xad, 0xbe, 0xef, 0x00, ' ', '\n'}
+	b64 := base64.StdEncoding.EncodeToString(payload)
+	files := map[string]string{
+		"data.bin": string(payload),
+		"data.hex": "dead beef\n0020 0a\n",
+		"data.b64": b64[:4] + "\n" + b64[4:] + "\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		stdin []byte
+		args  []string
+		want  []byte
+	}{
+		{"raw file", nil, []string{"add-section", "--file", "data.bin"}, payload},
+		{"hex file", nil, []string{"add-section", "--file", "data.hex", "--hex"}, payload},
+		{"base64 file", nil, []string{"add-section", "--file", "data.b64", "--base64"}, payload},
+		{"raw stdin", payload, []string{"add-section", "--file", "-"}, payload},
+		{"hex stdin", []byte(files["data.hex"]), []string{"add-section", "--file", "-", "--hex"}, payload},
+		{"raw string", nil, []string{"add-section-string", "--content", " a\tb \n"}, []byte(" a\tb \n")},
+		{"hex string", nil, []string{"add-section-string", "--content", "DE AD\tbe ef 00 20 0a", "--hex"}, payload},
+		{"base64 string", nil, []string{"add-section-string", "--content", b64, "--base64"}, payload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append(tt.args, "--name", ".in", "--output", "out", "tiny64")
+			runOK(t, dir, tt.stdin, args...)
+			if got, err := openELF(t, dir, "out").Section(".in").Data(); err != nil || !bytes.Equal(got, tt.want) {
+				t.Errorf("section = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	// The ELF file can come from stdin, and then goes to stdout
+	r := runOK(t, dir, input, "add-section-string", "--name", ".in", "--content", "00ff", "--hex", "-")
+	ef, err := elf.NewFile(bytes.NewReader(r.stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ef.Section(".in").Data(); err != nil || !bytes.Equal(got, []byte{0, 0xff}) {
+		t.Errorf("section from stdin input = %q, %v", got, err)
+	}
+
+	for _, args := range [][]string{
+		{"add-section-string", "--content", "00", "--hex", "--base64", "tiny64"},
+		{"add-section-string", "--content", "0g", "--hex", "tiny64"},
+		{"add-section-string", "--content", "abc", "--hex", "tiny64"},
+		{"add-section-string", "--content", "!!!!", "--base64", "tiny64"},
+		{"add-section", "--file", "-", "-"},
+		{"add-section", "--file", "missing.bin", "tiny64"},
+	} {
+		args = append(args[:1], append([]string{"--name", ".bad", "--output", "bad"}, args[1:]...)...)
+		if r := runElfy(t, dir, input, args...); r.code == 0 {
+			t.Errorf("elfy %q succeeded", args)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "bad")); err == nil {
+			t.Fatalf("elfy %q wrote output", args)
+		}
+	}
+}
+
+// corruptLink writes a copy of tiny64 to dir as name with an out of range
+// sh_link in the header of .comment, which verify reports as an error.
+func corruptLink(t *testing.T, dir, name string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "tiny64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ef := openELF(t, dir, "tiny64")
+	shoff := binary.LittleEndian.Uint64(data[0x28:])
+	i := slices.IndexFunc(ef.Sections, func(s *elf.Section) bool { return s.Name == ".comment" })
+	binary.LittleEndian.PutUint32(data[shoff+uint64(i)*64+40:], 1000)
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	corruptLink(t, dir, "bad")
+
+	r := runElfy(t, dir, nil, "verify", "bad")
+	if r.code != exitFailure || !strings.Contains(string(r.stdout), "[section-link]") {
+		t.Errorf("exit %d, stdout %q", r.code, r.stdout)
+	}
+	if got := string(r.stderr); got != "Error: bad failed verification with 1 problem(s)\n" {
+		t.Errorf("stderr = %q", got)
+	}
+
+	// The JSON report is the only output
+	r = runElfy(t, dir, nil, "--format", "json", "verify", "bad")
+	var report verifyJSON
+	decodeJSON(t, r.stdout, &report)
+	if r.code != exitFailure || report.Errors != 1 || len(r.stderr) != 0 {
+		t.Errorf("exit %d with %+v, stderr %q", r.code, report, r.stderr)
+	}
+
+	// --verify refuses to write output that keeps the problem
+	r = runElfy(t, dir, nil, "add-section-string", "--name", ".x", "--content", "x", "--flags", "none", "--verify", "--output", "out", "bad")
+	if r.code != exitFailure || !strings.Contains(string(r.stderr), "nothing written: output failed verification") ||
+		!strings.Contains(string(r.stderr), "sh_link 1000 out of range") {
+		t.Errorf("exit %d: %s", r.code, r.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("output written: %v", err)
+	}
+	runOK(t, dir, nil, "add-section-string", "--name", ".x", "--content", "x", "--flags", "none", "--verify", "--output", "out", "tiny64")
+}
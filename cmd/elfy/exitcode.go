@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+
+	"github.com/xplshn/elfy"
+)
+
+// Exit statuses. Files that fail verify or --verify exit with exitFailure.
+const (
+	exitFailure    = 1 // Any other failure, including usage errors
+	exitInvalidArg = 2 // Invalid option, name or operation
+	exitNotELF     = 3 // Input is not an ELF file or uses an unsupported class or encoding
+	exitMalformed  = 4 // Input is a malformed ELF file
+	exitLimit      = 5 // Input or output exceeds a size limit
+	exitNotFound   = 6 // Section, segment, note, overlay or section contents not found
+	exitConflict   = 7 // Section already exists, is still referenced or the data does not fit
+	exitFileAccess = 8 // A file cannot be read or written
+)
+
+// exitCode returns the exit status that reports err.
+func exitCode(err error) int {
+	var formatErr *elfy.FormatError
+	var limitErr *elfy.LimitError
+	var pathErr *fs.PathError
+	var verifyErr *verifyError
+	switch {
+	case errors.As(err, &verifyErr):
+		return exitFailure
+	case errors.Is(err, elfy.ErrInvalidArgument):
+		return exitInvalidArg
+	case errors.Is(err, elfy.ErrNotELF), errors.Is(err, elfy.ErrUnsupportedClass), errors.Is(err, elfy.ErrUnsupportedEncoding):
+		return exitNotELF
+	case errors.As(err, &formatErr):
+		return exitMalformed
+	case errors.As(err, &limitErr):
+		return exitLimit
+	case errors.Is(err, elfy.ErrSectionNotFound), errors.Is(err, elfy.ErrSegmentNotFound),
+		errors.Is(err, elfy.ErrNoteNotFound), errors.Is(err, elfy.ErrNoSymbols), errors.Is(err, elfy.ErrSymbolNotFound),
+		errors.Is(err, elfy.ErrNoOverlay), errors.Is(err, elfy.ErrNoData):
+		return exitNotFound
+	case errors.Is(err, elfy.ErrSectionExists), errors.Is(err, elfy.ErrSectionReferenced), errors.Is(err, elfy.ErrDoesNotFit),
+		errors.Is(err, elfy.ErrSymbolExists), errors.Is(err, elfy.ErrSymbolReferenced):
+		return exitConflict
+	case errors.As(err, &pathErr):
+		return exitFileAccess
+	}
+	return exitFailure
+}
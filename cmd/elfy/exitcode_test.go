@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xplshn/elfy"
+)
+
+func TestExitCode(t *testing.T) {
+	wrap := func(err error) error { return fmt.Errorf("operation 1 (remove .x): %w", err) }
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("missing input ELF file"), exitFailure},
+		{&verifyError{file: "a.out"}, exitFailure},
+		{fmt.Errorf("nothing written: %w", &verifyError{file: "output"}), exitFailure},
+		{elfy.ErrInvalidArgument, exitInvalidArg},
+		{wrap(elfy.ErrInvalidArgument), exitInvalidArg},
+		{elfy.ErrNotELF, exitNotELF},
+		{elfy.ErrUnsupportedClass, exitNotELF},
+		{elfy.ErrUnsupportedEncoding, exitNotELF},
+		{&elfy.FormatError{Field: "e_shoff", Msg: "bad"}, exitMalformed},
+		{wrap(&elfy.FormatError{Msg: "bad", Err: elfy.ErrNoData}), exitMalformed},
+		{&elfy.LimitError{Limit: "MaxFileSize"}, exitLimit},
+		{elfy.ErrSectionNotFound, exitNotFound},
+		{elfy.ErrSegmentNotFound, exitNotFound},
+		{elfy.ErrNoteNotFound, exitNotFound},
+		{elfy.ErrNoSymbols, exitNotFound},
+		{elfy.ErrSymbolNotFound, exitNotFound},
+		{elfy.ErrNoOverlay, exitNotFound},
+		{wrap(elfy.ErrNoData), exitNotFound},
+		{elfy.ErrSectionExists, exitConflict},
+		{wrap(elfy.ErrSectionReferenced), exitConflict},
+		{elfy.ErrDoesNotFit, exitConflict},
+		{elfy.ErrSymbolExists, exitConflict},
+		{elfy.ErrSymbolReferenced, exitConflict},
+		{&fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}, exitFileAccess},
+		{fmt.Errorf("error reading file: %w", &fs.PathError{Op: "open", Path: "x", Err: os.ErrPermission}), exitFileAccess},
+	}
+	for _, tt := range tests {
+		if got := exitCode(tt.err); got != tt.want {
+			t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	data, err := os.ReadFile(filepath.Join(dir, "tiny64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string][]byte{"script": []byte("#!/bin/sh\n"), "truncated": data[:len(data)-1]} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"list-sections"}, exitFailure},
+		{[]string{"list-sections", "--layout", "sideways", "tiny64"}, exitFailure},
+		{[]string{"remove-section", "--name", ".comment", "--layout", "sideways", "tiny64"}, exitInvalidArg},
+		{[]string{"list-sections", "script"}, exitNotELF},
+		{[]string{"list-sections", "truncated"}, exitMalformed},
+		{[]string{"read-section", "--name", ".nope", "tiny64"}, exitNotFound},
+		{[]string{"remove-section", "--name", ".dynstr", "tiny64"}, exitConflict},
+		{[]string{"list-sections", "missing"}, exitFileAccess},
+	}
+	for _, tt := range tests {
+		if r := runElfy(t, dir, nil, tt.args...); r.code != tt.want {
+			t.Errorf("elfy %q exited with %d, want %d: %s", tt.args, r.code, tt.want, r.stderr)
+		}
+	}
+}
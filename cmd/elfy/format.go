@@ -0,0 +1,290 @@
+package main
+
+import (
+	"debug/elf"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/xplshn/elfy"
+
+	"github.com/urfave/cli/v3"
+)
+
+// With --format json every command writes exactly one JSON object to stdout,
+// followed by a newline. Field names are stable; new fields may be added.
+//
+//	list-sections   {"sections": [section...]}
+//	list-segments   {"segments": [segment...]}
+//	symbols         {"symbols": [symbol...]}
+//	verify          {"file": "...", "errors": n, "warnings": n, "problems": [problem...]}
+//	read-section    {"name": "...", "offset": n, "size": n, "data": "<base64>"}
+//	add-section,
+//	add-section-string,
+//	remove-section,
+//	rename-section,
+//	edit-symbols,
+//	apply           {"operation": "add"|"remove"|"rename"|"edit-symbols"|"apply", "sections": ["..."],
+//	                 "output": "...", "dry_run": false, "plan": plan,
+//	                 "build_id": "<hex>" with --rebuild-id}
+//	notes list      {"notes": [note...]}
+//	notes read      note
+//	notes add,
+//	notes remove    {"operation": "add-note"|"remove-note", "sections": ["..."], ...} as above
+//	build-id        {"build_id": "<hex>", "section": "...", "size": n}, or the
+//	                result object above with --generate or --set
+//	embed           {"arch": "...", "section": "...", "size": n, "output": "...", "symbols": ["..."]}
+//	any failure     {"error": {"message": "...", "code": n}} with exit status code
+//
+// When an edited ELF file is written to stdout with --output -, the result
+// object goes to stderr instead.
+//
+// Types and flags use the names of the ELF specification, e.g. "SHT_NOTE",
+// "SHF_ALLOC" and "PF_R". Unknown values are rendered as hexadecimal numbers.
+
+var formatFlag = &cli.StringFlag{
+	Name:  "format",
+	Usage: "Output format: text or json",
+	Value: "text",
+	Validator: func(s string) error {
+		if s != "text" && s != "json" {
+			return fmt.Errorf("unknown output format %q", s)
+		}
+		return nil
+	},
+}
+
+// sectionJSON is the JSON form of a section header.
+type sectionJSON struct {
+	Index     int      `json:"index"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Flags     []string `json:"flags"`
+	Addr      uint64   `json:"addr"`
+	Offset    uint64   `json:"offset"`
+	Size      uint64   `json:"size"`
+	Link      uint32   `json:"link"`
+	Info      uint32   `json:"info"`
+	Addralign uint64   `json:"addralign"`
+	Entsize   uint64   `json:"entsize"`
+}
+
+// progJSON is the JSON form of a program header.
+type progJSON struct {
+	Type   string   `json:"type"`
+	Flags  []string `json:"flags"`
+	Offset uint64   `json:"offset"`
+	Vaddr  uint64   `json:"vaddr"`
+	Paddr  uint64   `json:"paddr"`
+	Filesz uint64   `json:"filesz"`
+	Memsz  uint64   `json:"memsz"`
+	Align  uint64   `json:"align"`
+}
+
+// segmentJSON is the JSON form of a program header and the sections it covers.
+type segmentJSON struct {
+	Index int `json:"index"`
+	progJSON
+	Sections []string `json:"sections"`
+}
+
+// sectionDataJSON is the JSON form of section contents.
+type sectionDataJSON struct {
+	Name   string `json:"name"`
+	Offset uint64 `json:"offset"`
+	Size   int    `json:"size"`
+	Data   string `json:"data"`
+}
+
+// resultJSON is the JSON form of the outcome of a command that edits a file.
+type resultJSON struct {
+	Operation string    `json:"operation"`
+	Sections  []string  `json:"sections"`
+	Output    string    `json:"output,omitempty"`
+	DryRun    bool      `json:"dry_run"`
+	Plan      *planJSON `json:"plan,omitempty"`
+	BuildID   string    `json:"build_id,omitempty"`
+}
+
+// buildIDJSON is the JSON form of the build-id command without edits.
+type buildIDJSON struct {
+	BuildID string `json:"build_id"`
+	Section string `json:"section"`
+	Size    int    `json:"size"`
+}
+
+// embedJSON is the JSON form of the outcome of the embed command.
+type embedJSON struct {
+	Arch    string   `json:"arch"`
+	Section string   `json:"section"`
+	Size    int      `json:"size"`
+	Output  string   `json:"output"`
+	Symbols []string `json:"symbols"`
+}
+
+// symbolJSON is the JSON form of an elfy.Symbol.
+type symbolJSON struct {
+	Index      int    `json:"index"`
+	Name       string `json:"name"`
+	Value      uint64 `json:"value"`
+	Size       uint64 `json:"size"`
+	Type       string `json:"type"`
+	Bind       string `json:"bind"`
+	Visibility string `json:"visibility"`
+	Shndx      uint32 `json:"shndx"`
+	Section    string `json:"section"`
+	Version    string `json:"version,omitempty"`
+	Library    string `json:"library,omitempty"`
+	Hidden     bool   `json:"hidden,omitempty"`
+}
+
+// noteJSON is the JSON form of an elfy.Note.
+type noteJSON struct {
+	Section  string `json:"section"`
+	Segment  int    `json:"segment"`
+	Offset   uint64 `json:"offset"`
+	Name     string `json:"name"`
+	Type     uint32 `json:"type"`
+	TypeName string `json:"type_name,omitempty"`
+	Size     int    `json:"size"`
+	Desc     string `json:"desc"`
+}
+
+// planJSON is the JSON form of an elfy.LayoutPlan.
+type planJSON struct {
+	Strategy               string     `json:"strategy"`
+	Moves                  []moveJSON `json:"moves"`
+	AddedSegment           *progJSON  `json:"added_segment,omitempty"`
+	OldSectionHeaderOffset uint64     `json:"old_section_header_offset"`
+	SectionHeaderOffset    uint64     `json:"section_header_offset"`
+	ProgramHeaderOffset    uint64     `json:"program_header_offset"`
+	OldSize                uint64     `json:"old_size"`
+	Size                   uint64     `json:"size"`
+}
+
+// moveJSON is the JSON form of an elfy.SectionMove.
+type moveJSON struct {
+	Index     int    `json:"index"`
+	Name      string `json:"name"`
+	Added     bool   `json:"added"`
+	OldOffset uint64 `json:"old_offset"`
+	OldSize   uint64 `json:"old_size"`
+	NewOffset uint64 `json:"new_offset"`
+	NewSize   uint64 `json:"new_size"`
+}
+
+// verifyJSON is the JSON form of the result of the verify command.
+type verifyJSON struct {
+	File     string        `json:"file"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Problems []problemJSON `json:"problems"`
+}
+
+// problemJSON is the JSON form of an elfy.Problem.
+type problemJSON struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// errorJSON is the JSON form of a failure.
+type errorJSON struct {
+	Error struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+// jsonOutput reports whether --format json was given.
+func jsonOutput(c *cli.Command) bool {
+	return c.String("format") == "json"
+}
+
+// printJSON writes v to stdout as a single line of JSON.
+func printJSON(v any) error {
+	return encodeJSON(os.Stdout, v)
+}
+
+// encodeJSON writes v to w as a single line of JSON.
+func encodeJSON(w io.Writer, v any) error {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("error encoding JSON: %w", err)
+	}
+	return nil
+}
+
+// printError reports err on stdout as an error object.
+func printError(err error) {
+	var e errorJSON
+	e.Error.Message = err.Error()
+	e.Error.Code = exitCode(err)
+	_ = printJSON(e)
+}
+
+func newSectionJSON(s elfy.SectionInfo) sectionJSON {
+	return sectionJSON{
+		Index:     s.Index,
+		Name:      s.Name,
+		Type:      s.Type.String(),
+		Flags:     flagNames(uint64(s.Flags), func(bit uint64) string { return elf.SectionFlag(bit).String() }),
+		Addr:      s.Addr,
+		Offset:    s.Offset,
+		Size:      s.Size,
+		Link:      s.Link,
+		Info:      s.Info,
+		Addralign: s.Addralign,
+		Entsize:   s.Entsize,
+	}
+}
+
+func newProgJSON(p elf.ProgHeader) progJSON {
+	return progJSON{
+		Type:   p.Type.String(),
+		Flags:  flagNames(uint64(p.Flags), func(bit uint64) string { return elf.ProgFlag(bit).String() }),
+		Offset: p.Off,
+		Vaddr:  p.Vaddr,
+		Paddr:  p.Paddr,
+		Filesz: p.Filesz,
+		Memsz:  p.Memsz,
+		Align:  p.Align,
+	}
+}
+
+func newPlanJSON(p *elfy.LayoutPlan) *planJSON {
+	out := &planJSON{
+		Strategy:               p.Strategy.String(),
+		Moves:                  make([]moveJSON, 0, len(p.Moves)),
+		OldSectionHeaderOffset: p.OldSectionHeaderOffset,
+		SectionHeaderOffset:    p.SectionHeaderOffset,
+		ProgramHeaderOffset:    p.ProgramHeaderOffset,
+		OldSize:                p.OldSize,
+		Size:                   p.Size,
+	}
+	for _, m := range p.Moves {
+		out.Moves = append(out.Moves, moveJSON(m))
+	}
+	if p.AddedSegment != nil {
+		seg := newProgJSON(*p.AddedSegment)
+		out.AddedSegment = &seg
+	}
+	return out
+}
+
+func newSectionDataJSON(name string, data []byte) sectionDataJSON {
+	return sectionDataJSON{Name: name, Size: len(data), Data: base64.StdEncoding.EncodeToString(data)}
+}
+
+// flagNames splits a flag word into the names of its set bits.
+func flagNames(flags uint64, name func(bit uint64) string) []string {
+	names := []string{}
+	for bit := uint64(1); bit != 0; bit <<= 1 {
+		if flags&bit != 0 {
+			names = append(names, name(bit))
+		}
+	}
+	return names
+}
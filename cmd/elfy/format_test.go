@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/xplshn/elfy"
+)
+
+// decodeJSON checks that out holds exactly one line of JSON and decodes it into v.
+func decodeJSON(t *testing.T, out []byte, v any) {
+	t.Helper()
+	if bytes.Count(out, []byte("\n")) != 1 || !bytes.HasSuffix(out, []byte("\n")) {
+		t.Fatalf("output is not a single line of JSON: %q", out)
+	}
+	dec := json.NewDecoder(bytes.NewReader(out))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		t.Fatalf("error decoding %q: %v", out, err)
+	}
+}
+
+func TestJSONInspection(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	ef := openELF(t, dir, "tiny64")
+
+	var sections struct{ Sections []sectionJSON }
+	decodeJSON(t, runOK(t, dir, nil, "--format", "json", "list-sections", "tiny64").stdout, &sections)
+	if len(sections.Sections) != len(ef.Sections) {
+		t.Fatalf("%d sections, want %d", len(sections.Sections), len(ef.Sections))
+	}
+	for i, s := range ef.Sections {
+		got := sections.Sections[i]
+		if got.Index != i || got.Name != s.Name || got.Type != s.Type.String() || got.Addr != s.Addr ||
+			got.Offset != s.Offset || got.Size != s.Size || got.Link != s.Link || got.Info != s.Info ||
+			got.Addralign != s.Addralign || got.Entsize != s.Entsize {
+			t.Errorf("section %d = %+v, want %+v", i, got, s.SectionHeader)
+		}
+	}
+	if text := sections.Sections[slices.IndexFunc(ef.Sections, func(s *elf.Section) bool { return s.Name == ".text" })]; !slices.Equal(text.Flags, []string{"SHF_ALLOC", "SHF_EXECINSTR"}) {
+		t.Errorf(".text flags = %q", text.Flags)
+	}
+
+	var segments struct{ Segments []segmentJSON }
+	decodeJSON(t, runOK(t, dir, nil, "--format", "json", "list-segments", "tiny64").stdout, &segments)
+	if len(segments.Segments) != len(ef.Progs) {
+		t.Fatalf("%d segments, want %d", len(segments.Segments), len(ef.Progs))
+	}
+	for i, p := range ef.Progs {
+		got := segments.Segments[i]
+		if got.Index != i || got.Type != p.Type.String() || got.Offset != p.Off || got.Vaddr != p.Vaddr || got.Filesz != p.Filesz || got.Memsz != p.Memsz {
+			t.Errorf("segment %d = %+v, want %+v", i, got, p.ProgHeader)
+		}
+		if p.Type == elf.PT_INTERP && !slices.Equal(got.Sections, []string{".interp"}) {
+			t.Errorf("PT_INTERP covers %q", got.Sections)
+		}
+	}
+
+	var data sectionDataJSON
+	decodeJSON(t, runOK(t, dir, nil, "--format", "json", "read-section", "--name", ".comment", "tiny64").stdout, &data)
+	comment, _ := ef.Section(".comment").Data()
+	if got, err := base64.StdEncoding.DecodeString(data.Data); err != nil || !bytes.Equal(got, comment) || data.Size != len(comment) || data.Name != ".comment" {
+		t.Errorf("read-section = %+v", data)
+	}
+
+	var verify verifyJSON
+	decodeJSON(t, runOK(t, dir, nil, "--format", "json", "verify", "tiny64").stdout, &verify)
+	if verify.File != "tiny64" || verify.Errors != 0 || verify.Errors+verify.Warnings != len(verify.Problems) {
+		t.Errorf("verify = %+v", verify)
+	}
+
+	var notes struct{ Notes []noteJSON }
+	decodeJSON(t, runOK(t, dir, nil, "--format", "json", "notes", "list", "tiny64").stdout, &notes)
+	id := slices.IndexFunc(notes.Notes, func(n noteJSON) bool { return n.TypeName == "NT_GNU_BUILD_ID" })
+	if id == -1 || notes.Notes[id].Section != ".note.gnu.build-id" || notes.Notes[id].Name != "GNU" || notes.Notes[id].Size != 20 {
+		t.Errorf("notes = %+v", notes.Notes)
+	}
+
+	var buildID buildIDJSON
+	decodeJSON(t, runOK(t, dir, nil, "--format", "json", "build-id", "tiny64").stdout, &buildID)
+	raw, err := os.ReadFile(filepath.Join(dir, "tiny64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := elfy.ReadBuildID(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buildID.BuildID != hex.EncodeToString(want) || buildID.Section != ".note.gnu.build-id" || buildID.Size != len(want) {
+		t.Errorf("build-id = %+v", buildID)
+	}
+
+	objDir := copyTestdata(t, "hello64.o")
+	var symbols struct{ Symbols []symbolJSON }
+	decodeJSON(t, runOK(t, objDir, nil, "--format", "json", "symbols", "hello64.o").stdout, &symbols)
+	syms, err := openELF(t, objDir, "hello64.o").Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols.Symbols) != len(syms) {
+		t.Fatalf("%d symbols, want %d", len(symbols.Symbols), len(syms))
+	}
+	for i, s := range syms {
+		got := symbols.Symbols[i]
+		if got.Index != i+1 || got.Name != s.Name || got.Value != s.Value || got.Shndx != uint32(s.Section) || got.Bind != elf.ST_BIND(s.Info).String() {
+			t.Errorf("symbol %d = %+v, want %+v", i+1, got, s)
+		}
+	}
+}
+
+func TestJSONEdits(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+
+	var res resultJSON
+	decodeJSON(t, runOK(t, dir, nil, "--format", "json", "add-section-string", "--name", ".x", "--content", "x", "--output", "out", "tiny64").stdout, &res)
+	if res.Operation != "add" || !slices.Equal(res.Sections, []string{".x"}) || res.Output != "out" || res.DryRun || res.Plan != nil {
+		t.Errorf("add-section-string = %+v", res)
+	}
+
+	res = resultJSON{}
+	r := runOK(t, dir, nil, "--format", "json", "remove-section", "--name", ".x", "--dry-run", "out")
+	decodeJSON(t, r.stdout, &res)
+	if res.Operation != "remove" || !res.DryRun || res.Plan == nil || res.Plan.Strategy != "reuse" || res.Output != "" {
+		t.Errorf("remove-section --dry-run = %+v", res)
+	}
+
+	// With the ELF file on stdout the result goes to stderr
+	res = resultJSON{}
+	r = runOK(t, dir, nil, "--format", "json", "rename-section", "--from", ".x", "--to", ".y", "--output", "-", "out")
+	decodeJSON(t, r.stderr, &res)
+	if res.Operation != "rename" || !slices.Equal(res.Sections, []string{".y"}) || res.Output != "-" {
+		t.Errorf("rename-section = %+v", res)
+	}
+	if _, err := elf.NewFile(bytes.NewReader(r.stdout)); err != nil {
+		t.Errorf("stdout is not the ELF file: %v", err)
+	}
+
+	var embed embedJSON
+	if err := os.WriteFile(filepath.Join(dir, "blob.bin"), []byte("blob"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	decodeJSON(t, runOK(t, dir, nil, "--format", "json", "embed", "--arch", "x86_64", "blob.bin").stdout, &embed)
+	if embed.Arch != "x86_64" || embed.Section != ".rodata" || embed.Size != 4 || embed.Output != "blob.o" || len(embed.Symbols) != 3 {
+		t.Errorf("embed = %+v", embed)
+	}
+
+	// Failures are reported as an error object with the exit status as code
+	var failure errorJSON
+	r = runElfy(t, dir, nil, "--format", "json", "read-section", "--name", ".nope", "tiny64")
+	decodeJSON(t, r.stdout, &failure)
+	if r.code != exitNotFound || failure.Error.Code != r.code || failure.Error.Message == "" {
+		t.Errorf("exit %d with %+v", r.code, failure)
+	}
+}
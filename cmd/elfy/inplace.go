@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// inPlaceOptions selects how writeInPlace treats the file it replaces.
+type inPlaceOptions struct {
+	followSymlinks bool // replace the target of a symlink instead of refusing
+	preserveTimes  bool // keep the access and modification times
+	force          bool // replace a file that has other hardlinks
+	strictAttrs    bool // fail instead of warning when the owner or extended attributes cannot be kept
+}
+
+// writeInPlace replaces the file at path with data. The data is written to a
+// temporary file in the same directory, which gets the permission bits, owner,
+// group and extended attributes of the original and is then renamed over it,
+// so readers never see a partial file and running executables keep their
+// mapping of the old contents. Symlinks are refused unless followSymlinks is
+// set, in which case their target is replaced. The rename gives path a new
+// inode, so other hardlinks to the original keep the old contents; files with
+// more than one link are refused unless force is set. An owner or extended
+// attribute that cannot be copied is a warning unless strictAttrs is set.
+// With preserveTimes the access and modification times of the original are
+// kept as well. The original is never truncated, so without a writable
+// directory the edit fails instead.
+func writeInPlace(path string, data []byte, opts inPlaceOptions) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("error reading file attributes: %w", err)
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if !opts.followSymlinks {
+			return fmt.Errorf("refusing to edit %s in place: it is a symlink (use --follow-symlinks)", path)
+		}
+		if path, err = filepath.EvalSymlinks(path); err != nil {
+			return fmt.Errorf("error resolving symlink: %w", err)
+		}
+		if fi, err = os.Stat(path); err != nil {
+			return fmt.Errorf("error reading file attributes: %w", err)
+		}
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("refusing to edit %s in place: not a regular file", path)
+	}
+	if n := linkCount(fi); n > 1 && !opts.force {
+		return fmt.Errorf("refusing to edit %s in place: it has %d hardlinks, which would keep the old contents (use --force)", path, n)
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".elfy-*")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file next to %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeTemp(tmp, data, path, fi, opts); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing %s: %w", path, err)
+	}
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// writeTemp fills the temporary file and copies the attributes of the original onto it.
+func writeTemp(tmp *os.File, data []byte, path string, fi os.FileInfo, opts inPlaceOptions) error {
+	if _, err := tmp.Write(data); err != nil {
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("error writing temporary file: %w", err)
+	}
+	// Changing the owner clears set-user-ID bits and file capabilities, so it comes first.
+	// Only root may give a file away, so other users editing a group-writable
+	// file end up owning the result
+	if err := preserveErr(copyOwner(tmp, fi), "owner", path, opts.strictAttrs); err != nil {
+		return err
+	}
+	if err := tmp.Chmod(fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)); err != nil {
+		return fmt.Errorf("error preserving permissions: %w", err)
+	}
+	if err := preserveErr(copyXattrs(tmp.Name(), path), "extended attributes", path, opts.strictAttrs); err != nil {
+		return err
+	}
+	if opts.preserveTimes {
+		atime, mtime := fileTimes(fi)
+		if err := os.Chtimes(tmp.Name(), atime, mtime); err != nil {
+			return fmt.Errorf("error preserving timestamps: %w", err)
+		}
+	}
+	return nil
+}
+
+// preserveErr decides what a failure to copy the attribute what of path means:
+// an error with strict, otherwise a warning on stderr and a nil result. A
+// missing permission or platform support is the usual cause, for example an
+// unprivileged user cannot give a file away or set trusted.* attributes.
+func preserveErr(err error, what, path string, strict bool) error {
+	if err == nil {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("error preserving %s of %s: %w", what, path, err)
+	}
+	warnf("%s of %s not preserved: %v", what, path, err)
+	return nil
+}
+
+// warnf reports a problem that does not stop the command on stderr.
+func warnf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "Warning: "+format+"\n", args...)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// fileTimes returns the access and modification times of the file described by fi.
+func fileTimes(fi os.FileInfo) (time.Time, time.Time) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fi.ModTime(), fi.ModTime()
+	}
+	return time.Unix(st.Atim.Unix()), fi.ModTime()
+}
+
+// copyXattrs copies every extended attribute of src, such as security.capability, to dst.
+func copyXattrs(dst, src string) error {
+	size, err := syscall.Listxattr(src, nil)
+	if errors.Is(err, syscall.ENOTSUP) || size == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	list := make([]byte, size)
+	if size, err = syscall.Listxattr(src, list); err != nil {
+		return err
+	}
+	for _, name := range bytes.Split(list[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		n, err := syscall.Getxattr(src, string(name), nil)
+		if err != nil {
+			return err
+		}
+		value := make([]byte, n)
+		if n, err = syscall.Getxattr(src, string(name), value); err != nil {
+			return err
+		}
+		if err := syscall.Setxattr(dst, string(name), value[:n], 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
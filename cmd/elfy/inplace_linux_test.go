@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestInPlaceXattrs(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	path := filepath.Join(dir, "tiny64")
+	value := []byte("kept")
+	if err := syscall.Setxattr(path, "user.elfy", value, 0); errors.Is(err, syscall.ENOTSUP) {
+		t.Skip("no user extended attributes on the temporary directory")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	runOK(t, dir, nil, "add-section-string", "--name", ".x", "--content", "x", "--in-place", "--strict-attrs", "tiny64")
+	got := make([]byte, 16)
+	n, err := syscall.Getxattr(path, "user.elfy", got)
+	if err != nil || string(got[:n]) != string(value) {
+		t.Errorf("user.elfy = %q, %v, want %q", got[:n], err, value)
+	}
+}
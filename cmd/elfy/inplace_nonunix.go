@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// copyOwner reports that file ownership cannot be copied on this platform.
+func copyOwner(dst *os.File, fi os.FileInfo) error {
+	return errors.ErrUnsupported
+}
+
+// linkCount reports a single link, as hardlinks cannot be counted on this platform.
+func linkCount(fi os.FileInfo) uint64 {
+	return 1
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// fileTimes returns the modification time of the file described by fi for both timestamps.
+func fileTimes(fi os.FileInfo) (time.Time, time.Time) {
+	return fi.ModTime(), fi.ModTime()
+}
+
+// copyXattrs reports that extended attributes cannot be copied on this platform.
+func copyXattrs(dst, src string) error {
+	return errors.ErrUnsupported
+}
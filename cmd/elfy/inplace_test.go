@@ -0,0 +1,164 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// addInPlace adds a small section to name in dir with --in-place and the extra flags.
+func addInPlace(t *testing.T, dir, name string, flags ...string) result {
+	t.Helper()
+	args := append([]string{"add-section-string", "--name", ".x", "--content", "x", "--in-place"}, flags...)
+	return runElfy(t, dir, nil, append(args, name)...)
+}
+
+// checkEdited reports whether the file at path has the section added by addInPlace.
+func checkEdited(t *testing.T, dir, name string, want bool) {
+	t.Helper()
+	if got := openELF(t, dir, name).Section(".x") != nil; got != want {
+		t.Errorf("%s has .x: %v, want %v", name, got, want)
+	}
+}
+
+func TestInPlace(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	path := filepath.Join(dir, "tiny64")
+	if err := os.Chmod(path, 0750|os.ModeSetgid); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if r := addInPlace(t, dir, "tiny64", "--preserve-times"); r.code != 0 {
+		t.Fatalf("exit %d: %s", r.code, r.stderr)
+	}
+	checkEdited(t, dir, "tiny64", true)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != 0750|os.ModeSetgid {
+		t.Errorf("mode = %v, want %v", fi.Mode(), 0750|os.ModeSetgid)
+	}
+	// Reading the input already counts as an access, so only the modification time is checked
+	if !fi.ModTime().Equal(old) {
+		t.Errorf("modification time = %v, want %v", fi.ModTime(), old)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+
+	// Without --preserve-times the file is as new as the edit
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	runOK(t, dir, nil, "remove-section", "--name", ".x", "--in-place", "tiny64")
+	if fi, err := os.Stat(path); err != nil || fi.ModTime().Equal(old) {
+		t.Errorf("modification time kept without --preserve-times: %v", err)
+	}
+}
+
+func TestInPlaceOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("giving a file away needs root")
+	}
+	dir := copyTestdata(t, "tiny64")
+	path := filepath.Join(dir, "tiny64")
+	if err := os.Chown(path, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+	runOK(t, dir, nil, "add-section-string", "--name", ".x", "--content", "x", "--in-place", "--strict-attrs", "tiny64")
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Uid != 65534 || st.Gid != 65534 {
+		t.Errorf("owner = %d:%d, want 65534:65534", st.Uid, st.Gid)
+	}
+}
+
+func TestInPlaceLinks(t *testing.T) {
+	dir := copyTestdata(t, "tiny64")
+	orig, err := os.ReadFile(filepath.Join(dir, "tiny64"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(dir, "tiny64"), filepath.Join(dir, "hardlink")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("tiny64", filepath.Join(dir, "symlink")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both kinds of links are refused without touching any file
+	for _, name := range []string{"hardlink", "symlink"} {
+		r := addInPlace(t, dir, name)
+		if r.code != exitFailure || !strings.Contains(string(r.stderr), "refusing to edit") {
+			t.Errorf("%s: exit %d: %s", name, r.code, r.stderr)
+		}
+	}
+	checkEdited(t, dir, "tiny64", false)
+
+	// --follow-symlinks edits the target, which still has a second link
+	r := addInPlace(t, dir, "symlink", "--follow-symlinks")
+	if r.code != exitFailure || !strings.Contains(string(r.stderr), "--force") {
+		t.Errorf("symlink to a hardlinked file: exit %d: %s", r.code, r.stderr)
+	}
+
+	// --force replaces one name and leaves the other with the old contents
+	if r := addInPlace(t, dir, "hardlink", "--force"); r.code != 0 {
+		t.Fatalf("exit %d: %s", r.code, r.stderr)
+	}
+	checkEdited(t, dir, "hardlink", true)
+	if data, err := os.ReadFile(filepath.Join(dir, "tiny64")); err != nil || !bytes.Equal(data, orig) {
+		t.Errorf("other hardlink changed: %v", err)
+	}
+
+	// Now that tiny64 has a single link the symlink can be followed
+	if r := addInPlace(t, dir, "symlink", "--follow-symlinks"); r.code != 0 {
+		t.Fatalf("exit %d: %s", r.code, r.stderr)
+	}
+	checkEdited(t, dir, "tiny64", true)
+	if fi, err := os.Lstat(filepath.Join(dir, "symlink")); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("symlink replaced: %v", err)
+	}
+}
+
+func TestPreserveErr(t *testing.T) {
+	if err := preserveErr(nil, "owner", "f", true); err != nil {
+		t.Errorf("no failure: %v", err)
+	}
+	failure := syscall.EPERM
+	if err := preserveErr(failure, "extended attributes", "f", true); !errors.Is(err, failure) {
+		t.Errorf("strict: error = %v, want %v", err, failure)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	err = preserveErr(failure, "extended attributes", "f", false)
+	os.Stderr = stderr
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if err != nil || !strings.HasPrefix(string(out), "Warning: extended attributes of f not preserved") {
+		t.Errorf("lenient: error = %v, stderr = %q", err, out)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/xplshn/elfy"
+
+	"github.com/urfave/cli/v3"
+)
+
+var (
+	noteNameFlag = &cli.StringFlag{
+		Name:     "name",
+		Usage:    "Owner of the note, e.g. GNU or FDO",
+		Required: true,
+	}
+	noteTypeFlag = &cli.StringFlag{
+		Name:     "type",
+		Usage:    "Note type, e.g. gnu_build_id, fdo_packaging_metadata or a number",
+		Required: true,
+	}
+)
+
+var notesCommand = &cli.Command{
+	Name:  "notes",
+	Usage: "List, read, add and remove ELF notes",
+	Commands: []*cli.Command{
+		{
+			Name:      "list",
+			Usage:     "List the notes of every SHT_NOTE section and PT_NOTE segment",
+			Action:    listNotes,
+			ArgsUsage: "<input_elf_file|->",
+		},
+		{
+			Name:  "read",
+			Usage: "Print the descriptor of a note as hex",
+			Flags: []cli.Flag{
+				noteNameFlag,
+				noteTypeFlag,
+				&cli.StringFlag{
+					Name:  "section",
+					Usage: "Only look in this section",
+				},
+				&cli.BoolFlag{
+					Name:  "raw",
+					Usage: "Write the exact descriptor bytes",
+				},
+				&cli.StringFlag{
+					Name:  "output",
+					Usage: "Write to this file instead of stdout",
+				},
+			},
+			Action:    readNote,
+			ArgsUsage: "<input_elf_file|->",
+		},
+		{
+			Name:  "add",
+			Usage: "Add a note or replace the note with the same owner and type",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "section",
+					Usage: "Note section to store the note in; created if missing (default: the section holding the note being replaced)",
+				},
+				noteNameFlag,
+				noteTypeFlag,
+				&cli.StringFlag{
+					Name:  "desc",
+					Usage: "Descriptor given as a string",
+				},
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "File containing the descriptor, or - for stdin",
+				},
+				&cli.StringFlag{
+					Name:  "output",
+					Usage: "Output ELF file, or - for stdout",
+				},
+				hexInputFlag,
+				base64InputFlag,
+				layoutFlag,
+				dryRunFlag,
+				inPlaceFlag,
+				followSymlinksFlag,
+				preserveTimesFlag,
+				forceFlag,
+				strictAttrsFlag,
+				verifyFlag,
+				rebuildIDFlag,
+			},
+			Action:    addNote,
+			ArgsUsage: "<input_elf_file|->",
+		},
+		{
+			Name:  "remove",
+			Usage: "Remove every note with the given owner and type",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "section",
+					Usage: "Only remove notes from this section",
+				},
+				noteNameFlag,
+				noteTypeFlag,
+				&cli.StringFlag{
+					Name:  "output",
+					Usage: "Output ELF file, or - for stdout",
+				},
+				layoutFlag,
+				dryRunFlag,
+				inPlaceFlag,
+				followSymlinksFlag,
+				preserveTimesFlag,
+				forceFlag,
+				strictAttrsFlag,
+				verifyFlag,
+				rebuildIDFlag,
+			},
+			Action:    removeNote,
+			ArgsUsage: "<input_elf_file|->",
+		},
+	},
+}
+
+func listNotes(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	f, err := openInput(c.Args().First())
+	if err != nil {
+		return err
+	}
+	notes, err := f.Notes()
+	if err != nil {
+		return err
+	}
+	if jsonOutput(c) {
+		out := make([]noteJSON, 0, len(notes))
+		for _, n := range notes {
+			out = append(out, newNoteJSON(n))
+		}
+		return printJSON(map[string]any{"notes": out})
+	}
+	if len(notes) == 0 {
+		fmt.Println("There are no notes in this file.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Section\tOwner\tType\tSize\tDescription")
+	for _, n := range notes {
+		where := n.Section
+		if where == "" {
+			where = fmt.Sprintf("segment %d", n.Segment)
+		}
+		typ := elfy.NoteTypeName(n.Name, n.Type)
+		if typ == "" {
+			typ = fmt.Sprintf("0x%x", n.Type)
+		}
+		desc := hex.EncodeToString(n.Desc[:min(len(n.Desc), 32)])
+		if len(n.Desc) > 32 {
+			desc += "..."
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", where, n.Name, typ, len(n.Desc), desc)
+	}
+	return w.Flush()
+}
+
+func readNote(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	typ, err := elfy.ParseNoteType(c.String("type"))
+	if err != nil {
+		return err
+	}
+	f, err := openInput(c.Args().First())
+	if err != nil {
+		return err
+	}
+	notes, err := f.Notes()
+	if err != nil {
+		return err
+	}
+	name, section := c.String("name"), c.String("section")
+	i := slices.IndexFunc(notes, func(n elfy.Note) bool {
+		return n.Name == name && n.Type == typ && (section == "" || n.Section == section)
+	})
+	if i == -1 {
+		return fmt.Errorf("%w: %s type %d", elfy.ErrNoteNotFound, name, typ)
+	}
+	note := notes[i]
+
+	var out bytes.Buffer
+	switch {
+	case jsonOutput(c):
+		if err := encodeJSON(&out, newNoteJSON(note)); err != nil {
+			return err
+		}
+	case c.Bool("raw"):
+		out.Write(note.Desc)
+	default:
+		out.WriteString(hex.EncodeToString(note.Desc))
+		out.WriteByte('\n')
+	}
+	if outputFile := c.String("output"); outputFile != "" {
+		if err := os.WriteFile(outputFile, out.Bytes(), 0644); err != nil {
+			return fmt.Errorf("error writing output file: %w", err)
+		}
+		return nil
+	}
+	_, err = os.Stdout.Write(out.Bytes())
+	return err
+}
+
+func addNote(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	inputFile := c.Args().First()
+	typ, err := elfy.ParseNoteType(c.String("type"))
+	if err != nil {
+		return err
+	}
+	if c.IsSet("desc") == c.IsSet("file") {
+		return fmt.Errorf("exactly one of --desc and --file is required")
+	}
+	desc := []byte(c.String("desc"))
+	if filePath := c.String("file"); filePath != "" {
+		if filePath == "-" && inputFile == "-" {
+			return fmt.Errorf("the descriptor and the input ELF file cannot both be read from stdin")
+		}
+		if desc, err = readInput(filePath); err != nil {
+			return fmt.Errorf("error reading descriptor file: %w", err)
+		}
+	}
+	desc, err = decodeSectionData(c, desc)
+	if err != nil {
+		return err
+	}
+	f, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading ELF file: %w", err)
+	}
+	section := c.String("section")
+	if err := f.AddOrReplaceNote(section, c.String("name"), typ, desc); err != nil {
+		return fmt.Errorf("error adding note: %w", err)
+	}
+	if section == "" {
+		section = noteSection(f, c.String("name"), typ)
+	}
+	return finish(c, f, inputFile, "add-note", []string{section}, "Note stored in section %s of %s\n")
+}
+
+func removeNote(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	inputFile := c.Args().First()
+	typ, err := elfy.ParseNoteType(c.String("type"))
+	if err != nil {
+		return err
+	}
+	f, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading ELF file: %w", err)
+	}
+	section := c.String("section")
+	var sections []string
+	if notes, err := f.Notes(); err == nil {
+		for _, n := range notes {
+			if n.Name == c.String("name") && n.Type == typ && n.Section != "" && (section == "" || n.Section == section) && !slices.Contains(sections, n.Section) {
+				sections = append(sections, n.Section)
+			}
+		}
+	}
+	if err := f.RemoveNote(section, c.String("name"), typ); err != nil {
+		return fmt.Errorf("error removing note: %w", err)
+	}
+	return finish(c, f, inputFile, "remove-note", sections, "Note removed from section %s of %s\n")
+}
+
+// noteSection returns the section holding the note with the given owner and type.
+func noteSection(f *elfy.File, name string, typ uint32) string {
+	notes, _ := f.Notes()
+	for _, n := range notes {
+		if n.Name == name && n.Type == typ {
+			return n.Section
+		}
+	}
+	return ""
+}
+
+func newNoteJSON(n elfy.Note) noteJSON {
+	return noteJSON{
+		Section:  n.Section,
+		Segment:  n.Segment,
+		Offset:   n.Offset,
+		Name:     n.Name,
+		Type:     n.Type,
+		TypeName: elfy.NoteTypeName(n.Name, n.Type),
+		Size:     len(n.Desc),
+		Desc:     base64.StdEncoding.EncodeToString(n.Desc),
+	}
+}
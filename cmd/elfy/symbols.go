@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"debug/elf"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xplshn/elfy"
+
+	"github.com/urfave/cli/v3"
+)
+
+var symbolsCommand = &cli.Command{
+	Name:  "symbols",
+	Usage: "List the static or dynamic symbols with their GNU versions",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dynamic",
+			Usage: "List the dynamic symbol table (.dynsym) instead of .symtab",
+		},
+		&cli.StringFlag{
+			Name:  "name",
+			Usage: "Only list symbols whose name matches this glob pattern, e.g. 'str*'",
+		},
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "Only list symbols of this type, e.g. func or object",
+		},
+		&cli.StringFlag{
+			Name:  "bind",
+			Usage: "Only list symbols of this binding, e.g. global or weak",
+		},
+		&cli.StringFlag{
+			Name:  "section",
+			Usage: "Only list symbols defined in this section",
+		},
+		&cli.BoolFlag{
+			Name:  "defined",
+			Usage: "Only list symbols defined in the file",
+		},
+		&cli.BoolFlag{
+			Name:  "undefined",
+			Usage: "Only list undefined symbols",
+		},
+		&cli.BoolFlag{
+			Name:  "names",
+			Usage: "Print only the symbol names, one per line",
+		},
+	},
+	Action:    listSymbols,
+	ArgsUsage: "<input_elf_file|->",
+}
+
+var editSymbolsCommand = &cli.Command{
+	Name:  "edit-symbols",
+	Usage: "Remove, rename, localize, globalize, weaken, prefix or add symbols of .symtab, like objcopy",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "remove",
+			Usage: "Remove this symbol; may be repeated",
+		},
+		&cli.StringSliceFlag{
+			Name:  "rename",
+			Usage: "Rename a symbol, given as old=new; may be repeated",
+		},
+		&cli.StringSliceFlag{
+			Name:  "localize",
+			Usage: "Make this symbol local; may be repeated",
+		},
+		&cli.StringSliceFlag{
+			Name:  "keep-global",
+			Usage: "Make every other defined symbol local; may be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "keep-global-file",
+			Usage: "Read --keep-global names from this file, one per line; # starts a comment",
+		},
+		&cli.StringSliceFlag{
+			Name:  "globalize",
+			Usage: "Make this symbol global; may be repeated",
+		},
+		&cli.StringSliceFlag{
+			Name:  "weaken",
+			Usage: "Make this symbol weak; may be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "prefix",
+			Usage: "Prepend this string to every symbol name",
+		},
+		&cli.StringSliceFlag{
+			Name:  "add",
+			Usage: "Add a symbol, given as name=[section:]value[,flag...] with flags such as local, weak, func or object; may be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "Output ELF file, or - for stdout",
+		},
+		layoutFlag,
+		dryRunFlag,
+		inPlaceFlag,
+		followSymlinksFlag,
+		preserveTimesFlag,
+		forceFlag,
+		strictAttrsFlag,
+		verifyFlag,
+		rebuildIDFlag,
+	},
+	Action:    editSymbols,
+	ArgsUsage: "<input_elf_file|->",
+	// --add values contain commas, so repeated flags must not be split on them
+	DisableSliceFlagSeparator: true,
+}
+
+func listSymbols(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	if c.Bool("defined") && c.Bool("undefined") {
+		return fmt.Errorf("--defined and --undefined are mutually exclusive")
+	}
+	f, err := openInput(c.Args().First())
+	if err != nil {
+		return err
+	}
+	var syms []elfy.Symbol
+	if c.Bool("dynamic") {
+		syms, err = f.DynamicSymbols()
+	} else {
+		syms, err = f.Symbols()
+	}
+	if err != nil {
+		return err
+	}
+	syms, err = filterSymbols(c, syms)
+	if err != nil {
+		return err
+	}
+	if jsonOutput(c) {
+		out := make([]symbolJSON, 0, len(syms))
+		for _, sym := range syms {
+			out = append(out, newSymbolJSON(sym))
+		}
+		return printJSON(map[string]any{"symbols": out})
+	}
+	if c.Bool("names") {
+		for _, sym := range syms {
+			fmt.Println(sym.Name)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Num\tValue\tSize\tType\tBind\tVis\tNdx\tName")
+	for _, sym := range syms {
+		fmt.Fprintf(w, "%d\t%016x\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			sym.Index, sym.Value, sym.Size, strings.TrimPrefix(symbolType(sym.Type), "STT_"),
+			strings.TrimPrefix(symbolBind(sym.Bind), "STB_"), strings.TrimPrefix(sym.Visibility.String(), "STV_"),
+			symbolSection(sym.Shndx), sym)
+	}
+	return w.Flush()
+}
+
+func editSymbols(ctx context.Context, c *cli.Command) error {
+	if c.NArg() != 1 {
+		return fmt.Errorf("missing input ELF file")
+	}
+	inputFile := c.Args().First()
+	edits := elfy.SymbolEdits{
+		Remove:     c.StringSlice("remove"),
+		Localize:   c.StringSlice("localize"),
+		KeepGlobal: c.StringSlice("keep-global"),
+		Globalize:  c.StringSlice("globalize"),
+		Weaken:     c.StringSlice("weaken"),
+		Prefix:     c.String("prefix"),
+	}
+	if renames := c.StringSlice("rename"); len(renames) > 0 {
+		edits.Rename = make(map[string]string)
+		for _, r := range renames {
+			oldName, newName, ok := strings.Cut(r, "=")
+			if !ok {
+				return fmt.Errorf("%w: --rename %q is not of the form old=new", elfy.ErrInvalidArgument, r)
+			}
+			edits.Rename[oldName] = newName
+		}
+	}
+	if keepFile := c.String("keep-global-file"); keepFile != "" {
+		data, err := readInput(keepFile)
+		if err != nil {
+			return fmt.Errorf("error reading keep-global file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			line, _, _ = strings.Cut(line, "#")
+			if name := strings.TrimSpace(line); name != "" {
+				edits.KeepGlobal = append(edits.KeepGlobal, name)
+			}
+		}
+	}
+	for _, a := range c.StringSlice("add") {
+		sym, err := parseAddSymbol(a)
+		if err != nil {
+			return err
+		}
+		edits.Add = append(edits.Add, sym)
+	}
+
+	f, err := openInput(inputFile)
+	if err != nil {
+		return fmt.Errorf("error reading ELF file: %w", err)
+	}
+	if err := f.EditSymbols(edits); err != nil {
+		return fmt.Errorf("error editing symbols: %w", err)
+	}
+	var sections []string
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_SYMTAB {
+			sections = append(sections, s.Name)
+		}
+	}
+	return finish(c, f, inputFile, "edit-symbols", sections, "Symbols of %s updated in %s\n")
+}
+
+// parseAddSymbol parses a symbol given to --add as name=[section:]value[,flag...].
+// Without a section the symbol is absolute; flags name a binding, type or visibility.
+func parseAddSymbol(s string) (elfy.Symbol, error) {
+	name, spec, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return elfy.Symbol{}, fmt.Errorf("%w: --add %q is not of the form name=[section:]value[,flags]", elfy.ErrInvalidArgument, s)
+	}
+	sym := elfy.Symbol{Name: name, Bind: elf.STB_GLOBAL, Shndx: elf.SHN_ABS}
+	parts := strings.Split(spec, ",")
+	value := parts[0]
+	if i := strings.LastIndex(value, ":"); i != -1 {
+		sym.Section, value = value[:i], value[i+1:]
+	}
+	var err error
+	if sym.Value, err = strconv.ParseUint(value, 0, 64); err != nil {
+		return elfy.Symbol{}, fmt.Errorf("%w: invalid value %q of symbol %s", elfy.ErrInvalidArgument, value, name)
+	}
+	for _, flag := range parts[1:] {
+		switch strings.ToLower(strings.TrimSpace(flag)) {
+		case "function":
+			sym.Type = elf.STT_FUNC
+			continue
+		case "hidden":
+			sym.Visibility = elf.STV_HIDDEN
+			continue
+		case "protected":
+			sym.Visibility = elf.STV_PROTECTED
+			continue
+		}
+		if bind, err := elfy.ParseSymbolBind(flag); err == nil {
+			sym.Bind = bind
+		} else if typ, err := elfy.ParseSymbolType(flag); err == nil {
+			sym.Type = typ
+		} else {
+			return elfy.Symbol{}, fmt.Errorf("%w: unknown flag %q of symbol %s", elfy.ErrInvalidArgument, flag, name)
+		}
+	}
+	return sym, nil
+}
+
+// filterSymbols keeps the symbols matching the --name, --type, --bind,
+// --section, --defined and --undefined options.
+func filterSymbols(c *cli.Command, syms []elfy.Symbol) ([]elfy.Symbol, error) {
+	if c.IsSet("name") {
+		pattern := c.String("name")
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%w: invalid name pattern %q", elfy.ErrInvalidArgument, pattern)
+		}
+		syms = slices.DeleteFunc(syms, func(sym elfy.Symbol) bool {
+			ok, _ := path.Match(pattern, sym.Name)
+			return !ok
+		})
+	}
+	if c.IsSet("type") {
+		typ, err := elfy.ParseSymbolType(c.String("type"))
+		if err != nil {
+			return nil, err
+		}
+		syms = slices.DeleteFunc(syms, func(sym elfy.Symbol) bool { return sym.Type != typ })
+	}
+	if c.IsSet("bind") {
+		bind, err := elfy.ParseSymbolBind(c.String("bind"))
+		if err != nil {
+			return nil, err
+		}
+		syms = slices.DeleteFunc(syms, func(sym elfy.Symbol) bool { return sym.Bind != bind })
+	}
+	if c.IsSet("section") {
+		syms = slices.DeleteFunc(syms, func(sym elfy.Symbol) bool { return sym.Section != c.String("section") })
+	}
+	if c.Bool("defined") {
+		syms = slices.DeleteFunc(syms, func(sym elfy.Symbol) bool { return !sym.Defined() })
+	}
+	if c.Bool("undefined") {
+		syms = slices.DeleteFunc(syms, elfy.Symbol.Defined)
+	}
+	return syms, nil
+}
+
+// symbolBind names a symbol binding, including STB_GNU_UNIQUE, which debug/elf calls STB_LOOS.
+func symbolBind(bind elf.SymBind) string {
+	if bind == elf.STB_LOOS {
+		return "STB_GNU_UNIQUE"
+	}
+	return bind.String()
+}
+
+// symbolType names a symbol type, including STT_GNU_IFUNC, which debug/elf calls STT_LOOS.
+func symbolType(typ elf.SymType) string {
+	if typ == elf.STT_GNU_IFUNC {
+		return "STT_GNU_IFUNC"
+	}
+	return typ.String()
+}
+
+// symbolSection renders a symbol's section index the way readelf does, e.g. "UND", "ABS" or "12".
+func symbolSection(shndx elf.SectionIndex) string {
+	switch shndx {
+	case elf.SHN_UNDEF:
+		return "UND"
+	case elf.SHN_ABS:
+		return "ABS"
+	case elf.SHN_COMMON:
+		return "COM"
+	}
+	return fmt.Sprint(uint32(shndx))
+}
+
+func newSymbolJSON(sym elfy.Symbol) symbolJSON {
+	return symbolJSON{
+		Index:      sym.Index,
+		Name:       sym.Name,
+		Value:      sym.Value,
+		Size:       sym.Size,
+		Type:       symbolType(sym.Type),
+		Bind:       symbolBind(sym.Bind),
+		Visibility: sym.Visibility.String(),
+		Shndx:      uint32(sym.Shndx),
+		Section:    sym.Section,
+		Version:    sym.Version,
+		Library:    sym.Library,
+		Hidden:     sym.Hidden,
+	}
+}
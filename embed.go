@@ -0,0 +1,149 @@
+package elfy
+
+import (
+	"debug/elf"
+	"fmt"
+	"strings"
+)
+
+// Arch describes a target for which Embed produces objects.
+type Arch struct {
+	Name    string
+	Class   elf.Class
+	Data    elf.Data
+	Machine elf.Machine
+	Flags   uint32 // e_flags expected by the target's linker
+}
+
+// arches lists the targets known to LookupArch, with the e_flags of objects
+// produced by the target's default GNU toolchain.
+var arches = []Arch{
+	{"x86_64", elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_X86_64, 0},
+	{"i386", elf.ELFCLASS32, elf.ELFDATA2LSB, elf.EM_386, 0},
+	{"aarch64", elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_AARCH64, 0},
+	{"aarch64_be", elf.ELFCLASS64, elf.ELFDATA2MSB, elf.EM_AARCH64, 0},
+	{"arm", elf.ELFCLASS32, elf.ELFDATA2LSB, elf.EM_ARM, 0x05000000},   // EABI version 5
+	{"armeb", elf.ELFCLASS32, elf.ELFDATA2MSB, elf.EM_ARM, 0x05000000}, // EABI version 5
+	{"riscv64", elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_RISCV, 0x5},    // RVC, double-float ABI
+	{"riscv32", elf.ELFCLASS32, elf.ELFDATA2LSB, elf.EM_RISCV, 0x5},    // RVC, double-float ABI
+	{"ppc64le", elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_PPC64, 0x2},    // ELFv2 ABI
+	{"ppc64", elf.ELFCLASS64, elf.ELFDATA2MSB, elf.EM_PPC64, 0x1},      // ELFv1 ABI
+	{"ppc", elf.ELFCLASS32, elf.ELFDATA2MSB, elf.EM_PPC, 0},
+	{"mips", elf.ELFCLASS32, elf.ELFDATA2MSB, elf.EM_MIPS, 0x70001007},     // MIPS32r2, o32, PIC
+	{"mipsel", elf.ELFCLASS32, elf.ELFDATA2LSB, elf.EM_MIPS, 0x70001007},   // MIPS32r2, o32, PIC
+	{"mips64", elf.ELFCLASS64, elf.ELFDATA2MSB, elf.EM_MIPS, 0x80000007},   // MIPS64r2, n64, PIC
+	{"mips64el", elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_MIPS, 0x80000007}, // MIPS64r2, n64, PIC
+	{"s390x", elf.ELFCLASS64, elf.ELFDATA2MSB, elf.EM_S390, 0},
+	{"sparc64", elf.ELFCLASS64, elf.ELFDATA2MSB, elf.EM_SPARCV9, 0},
+	{"loongarch64", elf.ELFCLASS64, elf.ELFDATA2LSB, elf.EM_LOONGARCH, 0x43}, // LP64D, object ABI v1
+}
+
+// archAliases maps alternative target names, such as GOARCH values, to the names in arches.
+var archAliases = map[string]string{
+	"amd64": "x86_64", "x86-64": "x86_64", "386": "i386", "x86": "i386", "i686": "i386",
+	"arm64": "aarch64", "armhf": "arm", "armel": "arm", "powerpc64le": "ppc64le",
+	"powerpc64": "ppc64", "powerpc": "ppc", "mipsle": "mipsel", "mips64le": "mips64el",
+	"loong64": "loongarch64",
+}
+
+// Arches returns every target known to LookupArch.
+func Arches() []Arch {
+	return append([]Arch(nil), arches...)
+}
+
+// LookupArch returns the target with the given name, e.g. "x86_64", "aarch64"
+// or "mips64el". GOARCH names such as "amd64" and "arm64" are accepted too.
+func LookupArch(name string) (Arch, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := archAliases[name]; ok {
+		name = alias
+	}
+	for _, a := range arches {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return Arch{}, fmt.Errorf("%w: unknown architecture %q", ErrInvalidArgument, name)
+}
+
+// EmbedOptions controls the object produced by Embed.
+type EmbedOptions struct {
+	Arch     Arch
+	Name     string // Stem of the symbol names; characters other than letters and digits become underscores
+	Section  string // Section holding the data; ".rodata" if empty, or ".data" when Writable
+	Align    uint64 // Section alignment; 1 if zero
+	Writable bool   // Mark the section SHF_WRITE
+}
+
+// SectionName returns the name of the section Embed puts the data in.
+func (opts EmbedOptions) SectionName() string {
+	switch {
+	case opts.Section != "":
+		return opts.Section
+	case opts.Writable:
+		return ".data"
+	}
+	return ".rodata"
+}
+
+// EmbedSymbols returns the names of the symbols Embed defines for the given
+// name: the start and end of the data, and its size as an absolute symbol.
+// These are the names used by ld -r -b binary.
+func EmbedSymbols(name string) (start, end, size string) {
+	stem := []byte(name)
+	for i, c := range stem {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			stem[i] = '_'
+		}
+	}
+	prefix := "_binary_" + string(stem)
+	return prefix + "_start", prefix + "_end", prefix + "_size"
+}
+
+// Embed produces a relocatable object (ET_REL) that holds data in a single
+// section and defines the symbols returned by EmbedSymbols, so that it can be
+// linked into C or cgo programs on the target architecture. The object also
+// carries an empty .note.GNU-stack section so linkers do not make the stack
+// executable.
+//
+// Parameters:
+//   - data: The raw bytes to embed.
+//   - opts: The target and the names to use.
+//
+// Returns:
+//   - A byte slice containing the object file.
+//   - An error if the options are invalid.
+func Embed(data []byte, opts EmbedOptions) ([]byte, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("%w: missing symbol name", ErrInvalidArgument)
+	}
+	if opts.Arch.Machine == elf.EM_NONE {
+		return nil, fmt.Errorf("%w: missing architecture", ErrInvalidArgument)
+	}
+	section := opts.SectionName()
+	flags := elf.SHF_ALLOC
+	if opts.Writable {
+		flags |= elf.SHF_WRITE
+	}
+	align := max(opts.Align, 1)
+	if align&(align-1) != 0 {
+		return nil, fmt.Errorf("%w: section alignment %d is not a power of two", ErrInvalidArgument, align)
+	}
+	if opts.Arch.Class == elf.ELFCLASS32 && uint64(len(data)) > 1<<32-1 {
+		return nil, fmt.Errorf("%w: %d bytes do not fit into a 32-bit object", ErrInvalidArgument, len(data))
+	}
+
+	start, end, size := EmbedSymbols(opts.Name)
+	b := NewBuilder(opts.Arch.Class, opts.Arch.Data, elf.ET_REL, opts.Arch.Machine)
+	b.Flags = opts.Arch.Flags
+	b.Sections = []BuildSection{
+		{Name: section, Type: elf.SHT_PROGBITS, Flags: flags, Addralign: align, Data: data},
+		{Name: ".note.GNU-stack", Type: elf.SHT_PROGBITS, Addralign: 1},
+	}
+	b.Symbols = []BuildSymbol{
+		{Name: start, Section: section, Bind: elf.STB_GLOBAL},
+		{Name: end, Section: section, Value: uint64(len(data)), Bind: elf.STB_GLOBAL},
+		{Name: size, Shndx: elf.SHN_ABS, Value: uint64(len(data)), Bind: elf.STB_GLOBAL},
+	}
+	return b.Bytes()
+}
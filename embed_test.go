@@ -0,0 +1,47 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"testing"
+)
+
+func TestEmbed(t *testing.T) {
+	payload := []byte("embedded payload")
+	for _, arch := range Arches() {
+		t.Run(arch.Name, func(t *testing.T) {
+			obj, err := Embed(payload, EmbedOptions{Arch: arch, Name: "assets/logo.png", Align: 8})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ef, err := elf.NewFile(bytes.NewReader(obj))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ef.Type != elf.ET_REL || ef.Machine != arch.Machine || ef.Class != arch.Class || ef.Data != arch.Data {
+				t.Errorf("header = %v %v %v %v", ef.Type, ef.Machine, ef.Class, ef.Data)
+			}
+			data, err := ef.Section(".rodata").Data()
+			if err != nil || !bytes.Equal(data, payload) {
+				t.Errorf(".rodata = %q, %v", data, err)
+			}
+			syms, err := ef.Symbols()
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]uint64{
+				"_binary_assets_logo_png_start": 0,
+				"_binary_assets_logo_png_end":   uint64(len(payload)),
+				"_binary_assets_logo_png_size":  uint64(len(payload)),
+			}
+			for _, sym := range syms {
+				if v, ok := want[sym.Name]; ok && v == sym.Value && elf.ST_BIND(sym.Info) == elf.STB_GLOBAL {
+					delete(want, sym.Name)
+				}
+			}
+			if len(want) > 0 {
+				t.Errorf("missing symbols %v", want)
+			}
+		})
+	}
+}
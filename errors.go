@@ -0,0 +1,71 @@
+package elfy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned by the package, wrapped with additional context.
+// Test for them with errors.Is.
+var (
+	// ErrNotELF is returned for input that does not start with the ELF magic number.
+	ErrNotELF = errors.New("not an ELF file")
+	// ErrUnsupportedClass is returned for files that are neither ELFCLASS32 nor ELFCLASS64.
+	ErrUnsupportedClass = errors.New("unsupported ELF class")
+	// ErrUnsupportedEncoding is returned for files that are neither little nor big endian.
+	ErrUnsupportedEncoding = errors.New("unsupported ELF data encoding")
+	// ErrSectionNotFound is returned when no section has the requested name.
+	ErrSectionNotFound = errors.New("section not found")
+	// ErrSectionExists is returned when a section is added or renamed to a name already in use.
+	ErrSectionExists = errors.New("section already exists")
+	// ErrSectionReferenced is returned when removing a section that other sections or symbols still refer to.
+	ErrSectionReferenced = errors.New("section is still referenced")
+	// ErrSegmentNotFound is returned for a program header index that does not exist.
+	ErrSegmentNotFound = errors.New("segment not found")
+	// ErrNoteNotFound is returned when no note has the requested owner and type.
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrSymbolNotFound is returned when no symbol has the requested name.
+	ErrSymbolNotFound = errors.New("symbol not found")
+	// ErrSymbolExists is returned when a symbol would clash with a global or weak symbol of the same name.
+	ErrSymbolExists = errors.New("symbol already exists")
+	// ErrSymbolReferenced is returned when removing a symbol that relocations or section groups still refer to.
+	ErrSymbolReferenced = errors.New("symbol is still referenced")
+	// ErrNoSymbols is returned when the file has no symbol table of the requested kind.
+	ErrNoSymbols = errors.New("no symbol table")
+	// ErrNoOverlay is returned when an operation needs an overlay and the file has none.
+	ErrNoOverlay = errors.New("no overlay found")
+	// ErrNoData is returned when reading or overwriting the contents of a section that has none in the file.
+	ErrNoData = errors.New("section has no file contents")
+	// ErrDoesNotFit is returned when new contents are larger than the room available for an in-place overwrite.
+	ErrDoesNotFit = errors.New("data does not fit")
+	// ErrInvalidArgument is returned for invalid options, names and operations.
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
+// FormatError reports a malformed ELF structure.
+type FormatError struct {
+	Offset uint64 // File offset of the offending structure or data
+	Field  string // Name of the offending field or structure, e.g. "e_shentsize" or "sh_offset"
+	Msg    string // Description of the problem
+	Err    error  // Underlying error, if any
+}
+
+func (e *FormatError) Error() string {
+	msg := "malformed ELF file: " + e.Msg
+	if e.Field != "" {
+		msg += fmt.Sprintf(" (%s at offset 0x%x)", e.Field, e.Offset)
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// formatErrorf returns a *FormatError for the field at off.
+func formatErrorf(off uint64, field, format string, args ...any) *FormatError {
+	return &FormatError{Offset: off, Field: field, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,77 @@
+package elfy
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	tiny, f := readFile(t, "tiny64")
+	exec, _ := buildFile(t, testBuilders()["x86_64/exec"])
+	group, _ := buildFile(t, groupBuilder("x86_64"))
+	patch := func(off int, b byte) []byte {
+		data := slices.Clone(tiny)
+		data[off] = b
+		return data
+	}
+	second := func(_ any, err error) error { return err }
+
+	tests := []struct {
+		name  string
+		call  func() error
+		check error
+	}{
+		{"not ELF", func() error { return second(Parse([]byte("#!/bin/sh\n"))) }, ErrNotELF},
+		{"class", func() error { return second(Parse(patch(4, 3))) }, ErrUnsupportedClass},
+		{"encoding", func() error { return second(Parse(patch(5, 3))) }, ErrUnsupportedEncoding},
+		{"section", func() error { return second(ReadSection(tiny, ".nope")) }, ErrSectionNotFound},
+		{"section exists", func() error { return second(RenameSection(tiny, ".comment", ".text")) }, ErrSectionExists},
+		{"section referenced", func() error { return second(RemoveSection(tiny, ".dynstr")) }, ErrSectionReferenced},
+		{"segment", func() error { return f.RemoveSegment(len(f.Segments)) }, ErrSegmentNotFound},
+		{"note", func() error { return second(RemoveNote(tiny, "", "GNU", 99)) }, ErrNoteNotFound},
+		{"symbol", func() error { return second(EditSymbols(exec, SymbolEdits{Weaken: []string{"nope"}})) }, ErrSymbolNotFound},
+		{"symbol exists", func() error {
+			return second(EditSymbols(exec, SymbolEdits{Rename: map[string]string{"buffer": "_start"}}))
+		}, ErrSymbolExists},
+		{"symbol referenced", func() error { return second(EditSymbols(group, SymbolEdits{Remove: []string{"a"}})) }, ErrSymbolReferenced},
+		{"no symbols", func() error { return second(ReadSymbols(tiny)) }, ErrNoSymbols},
+		{"no overlay", func() error { return second(StripOverlay(tiny)) }, ErrNoOverlay},
+		{"no data", func() error { return second(ReadSection(tiny, ".bss")) }, ErrNoData},
+		{"does not fit", func() error { return second(ReplaceSectionInPlace(tiny, ".interp", make([]byte, 4096), true)) }, ErrDoesNotFit},
+		{"invalid argument", func() error { return second(ParseLayoutStrategy("sideways")) }, ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		err := tt.call()
+		if !errors.Is(err, tt.check) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.check)
+		}
+		var formatErr *FormatError
+		var limitErr *LimitError
+		if errors.As(err, &formatErr) || errors.As(err, &limitErr) {
+			t.Errorf("%s: error %v is also a %T", tt.name, err, err)
+		}
+	}
+}
+
+func TestErrorTypes(t *testing.T) {
+	tiny, _ := readFile(t, "tiny64")
+	// The section header table is at the end of the file
+	truncated := tiny[:len(tiny)-1]
+	var formatErr *FormatError
+	if _, err := Parse(truncated); !errors.As(err, &formatErr) || formatErr.Field == "" {
+		t.Errorf("truncated file: error = %#v, want a *FormatError naming the field", err)
+	}
+
+	limits := DefaultLimits
+	limits.MaxSections = 3
+	var limitErr *LimitError
+	if _, err := ParseWithLimits(tiny, limits); !errors.As(err, &limitErr) || limitErr.Limit != "MaxSections" || limitErr.Max != 3 || limitErr.Value <= 3 {
+		t.Errorf("section limit: error = %#v, want a *LimitError for MaxSections", err)
+	}
+	limits = DefaultLimits
+	limits.MaxFileSize = 100
+	if _, err := ParseWithLimits(tiny, limits); !errors.As(err, &limitErr) || limitErr.Limit != "MaxFileSize" || limitErr.Value != uint64(len(tiny)) {
+		t.Errorf("file size limit: error = %#v, want a *LimitError for MaxFileSize", err)
+	}
+}
@@ -0,0 +1,579 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+// File is a parsed, mutable ELF file.
+// It is produced once by Open or Parse and can then be edited any number of
+// times before being serialized with Bytes or WriteTo.
+type File struct {
+	elf.FileHeader
+
+	// Sections holds the section headers in table order, including the null section at index 0.
+	Sections []*Section
+	// Segments holds the program headers in table order.
+	Segments []*Segment
+
+	ident     [elf.EI_NIDENT]byte
+	flags     uint32
+	phoff     uint64
+	shoff     uint64
+	shnum     int // Number of sections in the parsed file
+	ehsize    uint16
+	phentsize uint16
+	phnum     uint16
+	shentsize uint16
+
+	// Layout selects how Bytes places sections whose contents have to move.
+	Layout LayoutStrategy
+	// Limits bounds the size of the output produced by Bytes.
+	Limits Limits
+
+	origExtents []extent // Byte ranges of the parsed sections and section header table
+	shstrtab    *Section // Section header string table
+	overlay     []byte   // Trailing data after the end of the ELF image
+	overlayOff  uint64   // Offset of the overlay in the original file
+	raw         []byte   // Original file contents
+}
+
+// Section is a single section of a File.
+// Header fields can be inspected and modified directly; contents are accessed through Data and SetData.
+type Section struct {
+	Name      string
+	Type      elf.SectionType
+	Flags     elf.SectionFlag
+	Addr      uint64
+	Offset    uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Addralign uint64
+	Entsize   uint64
+
+	nameOff  uint32
+	origSize uint64 // Size in the parsed file
+	file     *File
+	data     []byte // Replacement contents, only meaningful when dirty is set
+	dirty    bool   // Contents no longer live at Offset in file.raw
+	inPlace  bool   // Replacement contents are written over the original range
+	load     bool   // Contents are mapped by a new PT_LOAD segment
+	added    bool   // Section did not exist in the parsed file
+}
+
+// Open reads and parses the ELF file at the given path.
+//
+// Parameters:
+//   - path: The path of the ELF file to open.
+//
+// Returns:
+//   - A pointer to the parsed File.
+//   - An error if the file cannot be read or is not a valid ELF file.
+func Open(path string) (*File, error) {
+	return OpenWithLimits(path, DefaultLimits)
+}
+
+// OpenWithLimits reads and parses the ELF file at the given path, refusing
+// files that exceed limits before reading them.
+//
+// Parameters:
+//   - path: The path of the ELF file to open.
+//   - limits: The limits to enforce.
+//
+// Returns:
+//   - A pointer to the parsed File.
+//   - An error if the file cannot be read, exceeds limits or is not a valid ELF file.
+func OpenWithLimits(path string, limits Limits) (*File, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	if fi.Mode().IsRegular() {
+		if err := checkLimit("MaxFileSize", uint64(fi.Size()), limits.MaxFileSize); err != nil {
+			return nil, err
+		}
+	}
+	elfData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	return ParseWithLimits(elfData, limits)
+}
+
+// Parse parses the provided ELF data into a mutable File.
+// The returned File keeps a reference to elfData, which must not be modified afterwards.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//
+// Returns:
+//   - A pointer to the parsed File.
+//   - An error if the ELF data is invalid or cannot be parsed.
+func Parse(elfData []byte) (*File, error) {
+	return ParseWithLimits(elfData, DefaultLimits)
+}
+
+// ParseWithLimits parses the provided ELF data like Parse, refusing input that
+// exceeds limits. Every offset and count read from the file is checked against
+// the size of elfData, so malformed input results in an error rather than a
+// panic or an allocation larger than the input.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//   - limits: The limits to enforce; they are kept in File.Limits.
+//
+// Returns:
+//   - A pointer to the parsed File.
+//   - An error if the ELF data is invalid, exceeds limits or cannot be parsed.
+func ParseWithLimits(elfData []byte, limits Limits) (*File, error) {
+	if err := checkLimit("MaxFileSize", uint64(len(elfData)), limits.MaxFileSize); err != nil {
+		return nil, err
+	}
+	if len(elfData) < elf.EI_NIDENT || !bytes.Equal(elfData[:4], []byte(elf.ELFMAG)) {
+		return nil, fmt.Errorf("error parsing ELF data: %w", ErrNotELF)
+	}
+
+	f := &File{raw: elfData, Limits: limits}
+	copy(f.ident[:], elfData[:elf.EI_NIDENT])
+	f.Class = elf.Class(f.ident[elf.EI_CLASS])
+	f.Data = elf.Data(f.ident[elf.EI_DATA])
+	f.Version = elf.Version(f.ident[elf.EI_VERSION])
+	f.OSABI = elf.OSABI(f.ident[elf.EI_OSABI])
+	f.ABIVersion = f.ident[elf.EI_ABIVERSION]
+
+	switch f.Data {
+	case elf.ELFDATA2LSB:
+		f.ByteOrder = binary.LittleEndian
+	case elf.ELFDATA2MSB:
+		f.ByteOrder = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedEncoding, f.Data)
+	}
+
+	if err := f.readHeader(); err != nil {
+		return nil, err
+	}
+	if err := f.readSections(); err != nil {
+		return nil, err
+	}
+	if err := f.readSegments(); err != nil {
+		return nil, err
+	}
+	f.overlayOff = f.imageEnd()
+	if f.overlayOff < uint64(len(elfData)) {
+		f.overlay = elfData[f.overlayOff:]
+	}
+	return f, nil
+}
+
+// readHeader decodes the ELF file header into f.
+func (f *File) readHeader() error {
+	r := bytes.NewReader(f.raw)
+	switch f.Class {
+	case elf.ELFCLASS64:
+		var hdr elf.Header64
+		if err := binary.Read(r, f.ByteOrder, &hdr); err != nil {
+			return &FormatError{Field: "ELF header", Msg: "truncated ELF header", Err: err}
+		}
+		f.Type = elf.Type(hdr.Type)
+		f.Machine = elf.Machine(hdr.Machine)
+		f.Entry = hdr.Entry
+		f.flags = hdr.Flags
+		f.phoff = hdr.Phoff
+		f.shoff = hdr.Shoff
+		f.ehsize = hdr.Ehsize
+		f.phentsize = hdr.Phentsize
+		f.phnum = hdr.Phnum
+		f.shentsize = hdr.Shentsize
+		return f.checkHeader(hdr.Shnum, hdr.Shstrndx)
+	case elf.ELFCLASS32:
+		var hdr elf.Header32
+		if err := binary.Read(r, f.ByteOrder, &hdr); err != nil {
+			return &FormatError{Field: "ELF header", Msg: "truncated ELF header", Err: err}
+		}
+		f.Type = elf.Type(hdr.Type)
+		f.Machine = elf.Machine(hdr.Machine)
+		f.Entry = uint64(hdr.Entry)
+		f.flags = hdr.Flags
+		f.phoff = uint64(hdr.Phoff)
+		f.shoff = uint64(hdr.Shoff)
+		f.ehsize = hdr.Ehsize
+		f.phentsize = hdr.Phentsize
+		f.phnum = hdr.Phnum
+		f.shentsize = hdr.Shentsize
+		return f.checkHeader(hdr.Shnum, hdr.Shstrndx)
+	default:
+		return fmt.Errorf("%w: %v", ErrUnsupportedClass, f.Class)
+	}
+}
+
+// checkHeader validates the section header table described by the ELF header
+// and allocates the (still empty) section list. Files with 65280 or more
+// sections keep the real count in sh_size and the real .shstrtab index in
+// sh_link of section 0 (extended section numbering).
+func (f *File) checkHeader(shnum, shstrndx uint16) error {
+	if shnum == 0 && f.shoff == 0 {
+		return nil
+	}
+	if int(f.shentsize) != f.sectionHeaderSize() {
+		return formatErrorf(0, "e_shentsize", "invalid section header entry size %d", f.shentsize)
+	}
+	if !inBounds(f.shoff, uint64(f.shentsize), uint64(len(f.raw))) {
+		return formatErrorf(f.shoff, "e_shoff", "section header table out of bounds")
+	}
+	count, strndx := uint64(shnum), uint32(shstrndx)
+	if shnum == 0 || shstrndx == uint16(elf.SHN_XINDEX) {
+		var first Section
+		if err := f.readSectionHeader(bytes.NewReader(f.raw[f.shoff:]), &first); err != nil {
+			return &FormatError{Offset: f.shoff, Field: "section header", Msg: "truncated section header", Err: err}
+		}
+		if shnum == 0 {
+			count = first.Size
+		}
+		if shstrndx == uint16(elf.SHN_XINDEX) {
+			strndx = first.Link
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	if err := checkLimit("MaxSections", count, uint64(f.Limits.MaxSections)); err != nil {
+		return err
+	}
+	if count > (uint64(len(f.raw))-f.shoff)/uint64(f.shentsize) {
+		return formatErrorf(f.shoff, "e_shnum", "section header table of %d entries out of bounds", count)
+	}
+	if uint64(strndx) >= count {
+		return formatErrorf(0, "e_shstrndx", "invalid .shstrtab index %d", strndx)
+	}
+	f.shnum = int(count)
+	f.Sections = make([]*Section, count)
+	f.shstrtab = &Section{}
+	f.Sections[strndx] = f.shstrtab
+	return nil
+}
+
+// readSections decodes every section header and resolves section names.
+func (f *File) readSections() error {
+	if len(f.Sections) == 0 {
+		return nil
+	}
+	r := bytes.NewReader(f.raw[f.shoff:])
+	for i := range f.Sections {
+		s := f.Sections[i]
+		if s == nil {
+			s = &Section{}
+			f.Sections[i] = s
+		}
+		if err := f.readSectionHeader(r, s); err != nil {
+			off := f.shoff + uint64(i)*uint64(f.shentsize)
+			return &FormatError{Offset: off, Field: "section header", Msg: "truncated section header", Err: err}
+		}
+		s.file = f
+		if i == 0 && s.Type == elf.SHT_NULL {
+			// The extended numbering fields of section 0 are recomputed when writing
+			s.Size, s.Link = 0, 0
+		}
+		s.origSize = s.Size
+		if s.Type != elf.SHT_NOBITS && s.Type != elf.SHT_NULL {
+			f.origExtents = append(f.origExtents, extent{s.Offset, s.Offset + s.Size})
+		}
+	}
+	f.origExtents = append(f.origExtents, extent{f.shoff, f.shoff + uint64(len(f.Sections))*uint64(f.shentsize)})
+
+	shstrtabData, err := f.shstrtab.Data()
+	if err != nil {
+		return fmt.Errorf("error reading .shstrtab: %w", err)
+	}
+	for _, s := range f.Sections {
+		s.Name = getString(shstrtabData, int(s.nameOff))
+	}
+	return nil
+}
+
+// readSectionHeader decodes a single class-specific section header from r into s.
+func (f *File) readSectionHeader(r *bytes.Reader, s *Section) error {
+	if f.Class == elf.ELFCLASS64 {
+		var sh elf.Section64
+		if err := binary.Read(r, f.ByteOrder, &sh); err != nil {
+			return err
+		}
+		s.nameOff = sh.Name
+		s.Type = elf.SectionType(sh.Type)
+		s.Flags = elf.SectionFlag(sh.Flags)
+		s.Addr = sh.Addr
+		s.Offset = sh.Off
+		s.Size = sh.Size
+		s.Link = sh.Link
+		s.Info = sh.Info
+		s.Addralign = sh.Addralign
+		s.Entsize = sh.Entsize
+		return nil
+	}
+	var sh elf.Section32
+	if err := binary.Read(r, f.ByteOrder, &sh); err != nil {
+		return err
+	}
+	s.nameOff = sh.Name
+	s.Type = elf.SectionType(sh.Type)
+	s.Flags = elf.SectionFlag(sh.Flags)
+	s.Addr = uint64(sh.Addr)
+	s.Offset = uint64(sh.Off)
+	s.Size = uint64(sh.Size)
+	s.Link = sh.Link
+	s.Info = sh.Info
+	s.Addralign = uint64(sh.Addralign)
+	s.Entsize = uint64(sh.Entsize)
+	return nil
+}
+
+// Section returns the first section with the given name, or nil if there is none.
+//
+// Parameters:
+//   - name: The name of the section to look up (e.g., ".text", ".data").
+//
+// Returns:
+//   - A pointer to the matching Section, or nil.
+func (f *File) Section(name string) *Section {
+	for _, s := range f.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// SectionNames returns the names of all sections in table order.
+func (f *File) SectionNames() []string {
+	names := make([]string, 0, len(f.Sections)) // Pre-allocate to reduce resizing
+	for _, s := range f.Sections {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+// SectionInfo is a snapshot of a section header.
+type SectionInfo struct {
+	Index     int
+	Name      string
+	Type      elf.SectionType
+	Flags     elf.SectionFlag
+	Addr      uint64
+	Offset    uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Addralign uint64
+	Entsize   uint64
+}
+
+// SectionTable returns the header fields of all sections in table order.
+// Offsets of sections with pending edits are the ones they had when parsed;
+// use PlanLayout to see where they will be written. Sections marked for loading
+// get their address once PlanLayout or Bytes has placed their segment.
+func (f *File) SectionTable() []SectionInfo {
+	infos := make([]SectionInfo, 0, len(f.Sections))
+	for i, s := range f.Sections {
+		infos = append(infos, SectionInfo{
+			Index:     i,
+			Name:      s.Name,
+			Type:      s.Type,
+			Flags:     s.Flags,
+			Addr:      s.Addr,
+			Offset:    s.Offset,
+			Size:      s.Size,
+			Link:      s.Link,
+			Info:      s.Info,
+			Addralign: s.Addralign,
+			Entsize:   s.Entsize,
+		})
+	}
+	return infos
+}
+
+// AddSection appends a new SHT_PROGBITS section with the given name and contents.
+//
+// Parameters:
+//   - name: The name of the new section.
+//   - data: The raw bytes to use as the section's content.
+//
+// Returns:
+//   - A pointer to the new Section.
+//   - An error if a section with that name already exists or the file has no section header string table.
+func (f *File) AddSection(name string, data []byte) (*Section, error) {
+	if f.Section(name) != nil {
+		return nil, fmt.Errorf("%w: %s", ErrSectionExists, name)
+	}
+	if f.shstrtab == nil {
+		return nil, formatErrorf(0, "e_shstrndx", "no section header string table")
+	}
+	s := &Section{
+		Name:      name,
+		Type:      elf.SHT_PROGBITS,
+		Flags:     elf.SHF_ALLOC,
+		Addralign: 1,
+		file:      f,
+		added:     true,
+	}
+	s.SetData(data)
+	f.Sections = append(f.Sections, s)
+	return s, nil
+}
+
+// AddOrReplaceSection adds a new section or replaces the contents of an existing one.
+// Replaced sections keep all their other header fields.
+//
+// Parameters:
+//   - name: The name of the section to add or replace.
+//   - data: The raw bytes to write as the section's content.
+//
+// Returns:
+//   - A pointer to the added or replaced Section.
+//   - An error if the section cannot be added.
+func (f *File) AddOrReplaceSection(name string, data []byte) (*Section, error) {
+	if s := f.Section(name); s != nil {
+		s.SetData(data)
+		return s, nil
+	}
+	return f.AddSection(name, data)
+}
+
+// ReplaceSectionInPlace overwrites the contents of an existing section at its
+// current file offset. See Section.SetDataInPlace.
+//
+// Parameters:
+//   - name: The name of the section to overwrite.
+//   - data: The raw bytes to write as the section's content.
+//   - pad: If true, sh_size is kept and the remainder is zero-filled; otherwise sh_size shrinks to len(data).
+//
+// Returns:
+//   - An error if the section is not found or the data does not fit.
+func (f *File) ReplaceSectionInPlace(name string, data []byte, pad bool) error {
+	s := f.Section(name)
+	if s == nil {
+		return fmt.Errorf("%w: %s", ErrSectionNotFound, name)
+	}
+	return s.SetDataInPlace(data, pad)
+}
+
+// Data returns a copy of the section's contents.
+//
+// Returns:
+//   - A byte slice containing the section's data.
+//   - An error if the section has no file contents or lies outside the file.
+func (s *Section) Data() ([]byte, error) {
+	if s.dirty {
+		return append([]byte(nil), s.data...), nil
+	}
+	if s.Type == elf.SHT_NOBITS {
+		return nil, fmt.Errorf("%w: %s is SHT_NOBITS", ErrNoData, s.Name)
+	}
+	raw := s.file.raw
+	if !inBounds(s.Offset, s.Size, uint64(len(raw))) {
+		return nil, formatErrorf(s.Offset, "sh_offset", "section %s data out of bounds", s.Name)
+	}
+	return append([]byte(nil), raw[s.Offset:s.Offset+s.Size]...), nil
+}
+
+// SetData replaces the section's contents and updates its size.
+// The new contents are placed when the file is written.
+//
+// Parameters:
+//   - data: The raw bytes to use as the section's content.
+func (s *Section) SetData(data []byte) {
+	s.data = append([]byte(nil), data...)
+	s.Size = uint64(len(data))
+	s.dirty = true
+	s.inPlace = false
+}
+
+// SetDataInPlace overwrites the section's contents at its existing file offset
+// instead of moving it, leaving every other byte of the file untouched.
+// It fails if the new contents do not fit into the section's original size.
+//
+// Parameters:
+//   - data: The raw bytes to use as the section's content.
+//   - pad: If true, sh_size is kept and the remainder is zero-filled; otherwise sh_size shrinks to len(data).
+//
+// Returns:
+//   - An error if the section has no file contents or data is too large.
+func (s *Section) SetDataInPlace(data []byte, pad bool) error {
+	if s.added || s.Type == elf.SHT_NOBITS {
+		return fmt.Errorf("%w: %s", ErrNoData, s.Name)
+	}
+	if !inBounds(s.Offset, s.origSize, uint64(len(s.file.raw))) {
+		return formatErrorf(s.Offset, "sh_offset", "section %s data out of bounds", s.Name)
+	}
+	if uint64(len(data)) > s.origSize {
+		return fmt.Errorf("%w: section %s too small for in-place replacement (%d > %d bytes)", ErrDoesNotFit, s.Name, len(data), s.origSize)
+	}
+	s.SetData(data)
+	if pad {
+		s.data = append(s.data, make([]byte, s.origSize-s.Size)...)
+		s.Size = s.origSize
+	}
+	s.inPlace = true
+	return nil
+}
+
+// Index returns the section's position in the section header table, or -1 if it was removed.
+func (s *Section) Index() int {
+	for i, sec := range s.file.Sections {
+		if sec == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// wordSize returns the natural alignment of the file's class: 8 bytes for 64-bit, 4 bytes for 32-bit.
+func (f *File) wordSize() uint64 {
+	if f.Class == elf.ELFCLASS64 {
+		return 8
+	}
+	return 4
+}
+
+// sectionHeaderSize returns the size of one section header entry for the file's class.
+func (f *File) sectionHeaderSize() int {
+	if f.Class == elf.ELFCLASS64 {
+		return 64
+	}
+	return 40
+}
+
+// progHeaderSize returns the size of one program header entry for the file's class.
+func (f *File) progHeaderSize() int {
+	if f.Class == elf.ELFCLASS64 {
+		return 56
+	}
+	return 32
+}
+
+// getString returns the NUL-terminated string starting at off in a string table.
+func getString(strtab []byte, off int) string {
+	if off < 0 || off >= len(strtab) {
+		return ""
+	}
+	end := bytes.IndexByte(strtab[off:], 0)
+	if end == -1 {
+		return string(strtab[off:])
+	}
+	return string(strtab[off : off+end])
+}
+
+// inBounds reports whether the range of size bytes at off lies within total bytes,
+// without overflowing.
+func inBounds(off, size, total uint64) bool {
+	return off <= total && size <= total-off
+}
+
+// findStringOffset returns the offset at which str can be read from the string
+// table data, including as the suffix of a longer string, or -1.
+func findStringOffset(data []byte, str string) int {
+	return bytes.Index(data, append([]byte(str), 0))
+}
@@ -0,0 +1,35 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"testing"
+)
+
+func TestSectionTable(t *testing.T) {
+	for _, name := range []string{"tiny64", "hello64.o", "hello32.o"} {
+		t.Run(name, func(t *testing.T) {
+			data, f := readFile(t, name)
+			ef, err := elf.NewFile(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			table := f.SectionTable()
+			if len(table) != len(ef.Sections) {
+				t.Fatalf("%d sections, debug/elf reads %d", len(table), len(ef.Sections))
+			}
+			for i, s := range ef.Sections {
+				want := SectionInfo{
+					Index: i, Name: s.Name, Type: s.Type, Flags: s.Flags, Addr: s.Addr, Offset: s.Offset,
+					Size: s.Size, Link: s.Link, Info: s.Info, Addralign: s.Addralign, Entsize: s.Entsize,
+				}
+				if table[i] != want {
+					t.Errorf("section %d = %+v, want %+v", i, table[i], want)
+				}
+			}
+			if infos, err := Sections(data); err != nil || len(infos) != len(table) || infos[len(infos)-1] != table[len(table)-1] {
+				t.Errorf("Sections differs from SectionTable: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package elfy
+
+import (
+	"debug/elf"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fuzzParse parses data with an output limit that keeps the fuzzing workers
+// well below the memory of a CI machine.
+func fuzzParse(data []byte) (*File, error) {
+	limits := DefaultLimits
+	limits.MaxOutputSize = 64 << 20
+	return ParseWithLimits(data, limits)
+}
+
+// addSeeds returns the contents of every file in testdata as seed inputs.
+func addSeeds(f *testing.F) [][]byte {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	var seeds [][]byte
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		seeds = append(seeds, data)
+	}
+	return seeds
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range addSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ef, err := fuzzParse(data)
+		if err != nil {
+			return
+		}
+		ef.SectionNames()
+		ef.SectionTable()
+		ef.Verify()
+		ef.CheckSegments()
+		ef.Overlay()
+		ef.Notes()
+		ef.BuildID()
+		ef.Symbols()
+		ef.DynamicSymbols()
+		for _, s := range ef.Sections {
+			s.Data()
+		}
+		for _, seg := range ef.Segments {
+			ef.SegmentSections(seg)
+		}
+		for _, layout := range []LayoutStrategy{LayoutReuse, LayoutAppend, LayoutCompact} {
+			ef.Layout = layout
+			if _, err := ef.PlanLayout(); err != nil {
+				continue
+			}
+			ef.Bytes()
+		}
+	})
+}
+
+func FuzzInspect(f *testing.F) {
+	for _, seed := range addSeeds(f) {
+		f.Add(seed, ".text")
+		f.Add(seed, ".shstrtab")
+	}
+	f.Fuzz(func(t *testing.T, data []byte, name string) {
+		ListSections(data)
+		Sections(data)
+		ReadSection(data, name)
+		ListSegments(data)
+		ReadOverlay(data)
+		Verify(data)
+		ListNotes(data)
+		ReadBuildID(data)
+		ReadSymbols(data)
+		ReadDynamicSymbols(data)
+	})
+}
+
+func FuzzEdit(f *testing.F) {
+	for _, seed := range addSeeds(f) {
+		for op := range byte(14) {
+			f.Add(seed, op, ".text", ".new", []byte("payload"))
+			f.Add(seed, op, ".data", ".strtab", []byte{})
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte, op byte, name, other string, content []byte) {
+		ef, err := fuzzParse(data)
+		if err != nil {
+			return
+		}
+		noFlags := elf.SectionFlag(0)
+		switch op % 14 {
+		case 0:
+			_, err = ef.AddOrReplaceSection(name, content)
+		case 1:
+			_, err = ef.AddOrReplaceSectionWithOptions(name, content, SectionOptions{Flags: &noFlags, Load: len(content)%2 == 0})
+		case 2:
+			align := uint64(len(content))
+			err = ef.UpdateSection(name, SectionOptions{Addralign: &align})
+		case 3:
+			err = ef.ReplaceSectionInPlace(name, content, len(content)%2 == 0)
+		case 4:
+			err = ef.RemoveSection(name)
+		case 5:
+			_, err = ef.RemoveSectionCascade(name)
+		case 6:
+			err = ef.RenameSection(name, other)
+		case 7:
+			ef.SetOverlay(content)
+		case 8:
+			ef.StripOverlay()
+		case 9:
+			err = ef.Apply([]Operation{
+				{Kind: OpAdd, Name: other, Data: content},
+				{Kind: OpRename, Name: name, NewName: other + "2"},
+				{Kind: OpRemove, Name: other, Cascade: true},
+			})
+		case 10:
+			err = ef.AddOrReplaceNote(name, other, uint32(len(content)), content)
+		case 11:
+			err = ef.RemoveNote(name, other, uint32(len(content)))
+		case 12:
+			_, err = ef.RecomputeBuildID(BuildIDAlgorithm(len(content) % 3))
+		case 13:
+			err = ef.EditSymbols(SymbolEdits{
+				Rename:     map[string]string{name: other},
+				Localize:   []string{other},
+				KeepGlobal: []string{name},
+				Prefix:     string(content),
+				Add:        []Symbol{{Name: other + "_end", Section: name, Value: uint64(len(content))}},
+			})
+		}
+		if err == nil {
+			ef.Bytes()
+		}
+	})
+}
+
+func FuzzBuilder(f *testing.F) {
+	f.Add(byte(0), ".data", uint32(elf.SHT_PROGBITS), uint64(elf.SHF_ALLOC), byte(3), []byte("hello"), "GNU", "sym")
+	f.Add(byte(5), ".note.x", uint32(elf.SHT_NOTE), uint64(0), byte(2), []byte{1, 2, 3, 4}, "", "")
+	f.Fuzz(func(t *testing.T, target byte, name string, typ uint32, flags uint64, align byte, data []byte, owner, sym string) {
+		arches := Arches()
+		arch := arches[int(target)%len(arches)]
+		b := NewBuilder(arch.Class, arch.Data, []elf.Type{elf.ET_REL, elf.ET_EXEC, elf.ET_DYN}[target%3], arch.Machine)
+		// Alignments are kept small, as the output grows with them
+		b.Sections = []BuildSection{{Name: name, Type: elf.SectionType(typ), Flags: elf.SectionFlag(flags), Addralign: 1 << (align % 8), Data: data}}
+		b.Notes = []BuildNote{{Name: owner, Type: typ, Desc: data}}
+		b.Symbols = []BuildSymbol{{Name: sym, Section: name, Value: uint64(len(data)), Bind: elf.SymBind(align % 3)}}
+		b.Segments = []BuildSegment{{Type: elf.PT_LOAD, Flags: elf.PF_R, Sections: []string{name}, Headers: target%2 == 0}}
+		if out, err := b.Bytes(); err == nil {
+			if _, err := fuzzParse(out); err != nil {
+				t.Errorf("built file does not parse: %v", err)
+			}
+		}
+		Embed(data, EmbedOptions{Name: sym, Arch: arch, Align: uint64(1) << (align % 8)})
+	})
+}
+
+func FuzzParseManifest(f *testing.F) {
+	f.Add([]byte(`[{"op": "add", "name": ".x", "hex": "0011", "type": "note", "flags": "alloc", "align": 4}]`))
+	f.Add([]byte(`{"operations": [{"op": "rename", "name": ".a", "to": ".b"}, {"op": "remove", "name": ".c"}]}`))
+	f.Fuzz(func(t *testing.T, manifest []byte) {
+		ParseManifest(manifest, t.TempDir())
+	})
+}
+
+func FuzzParseNames(f *testing.F) {
+	f.Add("alloc,write")
+	f.Add("SHT_NOTE")
+	f.Add("0x10")
+	f.Add("compact")
+	f.Add("gnu_build_id")
+	f.Add("ifunc")
+	f.Fuzz(func(t *testing.T, s string) {
+		ParseSectionType(s)
+		ParseSectionFlags(s)
+		ParseLayoutStrategy(s)
+		ParseNoteType(s)
+		ParseBuildIDAlgorithm(s)
+		ParseSymbolBind(s)
+		ParseSymbolType(s)
+	})
+}
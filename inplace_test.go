@@ -0,0 +1,86 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"testing"
+)
+
+func TestReplaceSectionInPlace(t *testing.T) {
+	tests := []struct {
+		name    string
+		section string
+		data    []byte
+		pad     bool
+	}{
+		{"pad", ".comment", []byte("elfy\x00"), true},
+		{"shrink", ".comment", []byte("elfy\x00"), false},
+		{"allocated", ".rodata", []byte{1, 0, 2, 0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, f := readFile(t, "tiny64")
+			s := f.Section(tt.section)
+			start, end := s.Offset, s.Offset+s.Size
+			// The section header only changes when sh_size shrinks
+			hdr := f.shoff + uint64(s.Index())*uint64(f.sectionHeaderSize())
+			if tt.pad {
+				hdr = 0
+			}
+			if err := f.ReplaceSectionInPlace(tt.section, tt.data, tt.pad); err != nil {
+				t.Fatal(err)
+			}
+			out, err := f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(out) != len(data) {
+				t.Fatalf("size changed from %d to %d", len(data), len(out))
+			}
+			for i := range out {
+				inSection := uint64(i) >= start && uint64(i) < end
+				inHeader := hdr != 0 && uint64(i) >= hdr && uint64(i) < hdr+uint64(f.sectionHeaderSize())
+				if out[i] != data[i] && !inSection && !inHeader {
+					t.Fatalf("byte 0x%x outside %s changed", i, tt.section)
+				}
+			}
+			want := append(bytes.Clone(tt.data), make([]byte, end-start-uint64(len(tt.data)))...)
+			if !bytes.Equal(out[start:end], want) {
+				t.Errorf("section bytes = %x, want %x", out[start:end], want)
+			}
+
+			g := parseFile(t, out)
+			checkVerify(t, g)
+			n := g.Section(tt.section)
+			if n.Offset != start || (tt.pad && n.Size != end-start) || (!tt.pad && n.Size != uint64(len(tt.data))) {
+				t.Errorf("section header = offset 0x%x size %d", n.Offset, n.Size)
+			}
+		})
+	}
+}
+
+func TestReplaceSectionInPlaceErrors(t *testing.T) {
+	data, f := readFile(t, "tiny64")
+	if err := f.ReplaceSectionInPlace(".rodata", make([]byte, 5), true); !errors.Is(err, ErrDoesNotFit) {
+		t.Errorf("oversized data: error = %v, want %v", err, ErrDoesNotFit)
+	}
+	if err := f.ReplaceSectionInPlace(".bss", nil, false); !errors.Is(err, ErrNoData) {
+		t.Errorf("SHT_NOBITS section: error = %v, want %v", err, ErrNoData)
+	}
+	if _, err := f.AddOrReplaceSectionWithOptions(".new", []byte("x"), SectionOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.ReplaceSectionInPlace(".new", nil, false); !errors.Is(err, ErrNoData) {
+		t.Errorf("added section: error = %v, want %v", err, ErrNoData)
+	}
+	if s := f.Section(".rodata"); s.Size != 4 || s.Type != elf.SHT_PROGBITS {
+		t.Errorf("failed replacement changed .rodata: %+v", s)
+	}
+	if err := f.RemoveSection(".new"); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("failed replacements changed the file: %v", err)
+	}
+}
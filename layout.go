@@ -0,0 +1,340 @@
+package elfy
+
+import (
+	"cmp"
+	"debug/elf"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// LayoutStrategy selects how File.Bytes places sections whose contents have to move.
+// Allocated sections, sections covered by a segment, the ELF header and the
+// program header table never move under any strategy.
+type LayoutStrategy int
+
+const (
+	// LayoutReuse places changed sections into space freed by removed or replaced
+	// sections and by the old section header table before growing the file.
+	// Unchanged sections keep their offsets. This is the default.
+	LayoutReuse LayoutStrategy = iota
+	// LayoutAppend appends every changed section after the last unchanged byte
+	// and leaves freed space unused.
+	LayoutAppend
+	// LayoutCompact behaves like LayoutReuse but also moves every non-allocated
+	// section that is not covered by a segment, packing them into freed space
+	// and the tail of the file without gaps.
+	LayoutCompact
+)
+
+// String returns the name of the layout strategy.
+func (l LayoutStrategy) String() string {
+	switch l {
+	case LayoutReuse:
+		return "reuse"
+	case LayoutAppend:
+		return "append"
+	case LayoutCompact:
+		return "compact"
+	}
+	return fmt.Sprintf("LayoutStrategy(%d)", int(l))
+}
+
+// ParseLayoutStrategy returns the LayoutStrategy with the given name ("reuse", "append" or "compact").
+func ParseLayoutStrategy(name string) (LayoutStrategy, error) {
+	for _, l := range []LayoutStrategy{LayoutReuse, LayoutAppend, LayoutCompact} {
+		if l.String() == name {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown layout strategy %q", ErrInvalidArgument, name)
+}
+
+// SectionMove describes where a single section is placed by a LayoutPlan.
+type SectionMove struct {
+	Index     int    // Index in the new section header table
+	Name      string // Section name
+	Added     bool   // The section did not exist in the parsed file
+	OldOffset uint64 // Offset in the parsed file
+	OldSize   uint64 // Size in the parsed file
+	NewOffset uint64 // Offset in the output file
+	NewSize   uint64 // Size in the output file
+}
+
+// LayoutPlan is the result of planning where File.Bytes will put every section
+// that moves, the section header table and the overlay.
+type LayoutPlan struct {
+	Strategy LayoutStrategy
+	// Moves lists every section that is added or placed at a new offset, in output order.
+	Moves []SectionMove
+
+	OldSectionHeaderOffset uint64
+	SectionHeaderOffset    uint64
+	OldSize                uint64 // Size of the parsed file, including its overlay
+	Size                   uint64 // Size of the output file, including its overlay
+
+	// ProgramHeaderOffset is the offset of the program header table in the output file.
+	ProgramHeaderOffset uint64
+	// AddedSegment is the PT_LOAD segment created for sections added with SectionOptions.Load, if any.
+	AddedSegment *elf.ProgHeader
+
+	progs   []elf.ProgHeader    // Program headers to write
+	offsets map[*Section]uint64 // New offsets of moved sections
+	keep    uint64              // Number of leading original bytes carried over
+	free    []extent            // Freed ranges below keep, zeroed before placement
+}
+
+// String renders the plan as a human-readable report.
+func (p *LayoutPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "layout: %s\n", p.Strategy)
+	for _, m := range p.Moves {
+		if m.Added {
+			fmt.Fprintf(&b, "  [%2d] %-20s added                      -> 0x%08x (%d bytes)\n", m.Index, m.Name, m.NewOffset, m.NewSize)
+			continue
+		}
+		fmt.Fprintf(&b, "  [%2d] %-20s 0x%08x (%d bytes) -> 0x%08x (%d bytes)\n", m.Index, m.Name, m.OldOffset, m.OldSize, m.NewOffset, m.NewSize)
+	}
+	if seg := p.AddedSegment; seg != nil {
+		fmt.Fprintf(&b, "  new PT_LOAD: offset 0x%08x vaddr 0x%08x size %d flags %v\n", seg.Off, seg.Vaddr, seg.Filesz, seg.Flags)
+	}
+	fmt.Fprintf(&b, "  section headers: 0x%08x -> 0x%08x\n", p.OldSectionHeaderOffset, p.SectionHeaderOffset)
+	fmt.Fprintf(&b, "  file size: %d -> %d (%+d)\n", p.OldSize, p.Size, int64(p.Size)-int64(p.OldSize))
+	return b.String()
+}
+
+// extent is a half-open byte range [off, end) of the file.
+type extent struct {
+	off, end uint64
+}
+
+// PlanLayout computes where File.Bytes would place every section without writing anything.
+// It can be used as a dry run to report what moves where. The only change it
+// makes to the file is storing the addresses of sections marked for loading,
+// which are known once their segment is placed.
+//
+// Returns:
+//   - A pointer to the LayoutPlan for the current state of the file.
+//   - An error if the section names cannot be synchronized.
+func (f *File) PlanLayout() (*LayoutPlan, error) {
+	if err := f.syncNames(); err != nil {
+		return nil, err
+	}
+	plan := &LayoutPlan{
+		Strategy:               f.Layout,
+		OldSectionHeaderOffset: f.shoff,
+		OldSize:                uint64(len(f.raw)),
+		ProgramHeaderOffset:    f.phoff,
+		offsets:                make(map[*Section]uint64),
+	}
+
+	// Bytes that have to stay where they are
+	fixed := []extent{{0, uint64(f.ehsize)}}
+	if f.phnum > 0 {
+		fixed = append(fixed, extent{f.phoff, f.phoff + uint64(f.phnum)*uint64(f.phentsize)})
+	}
+	for _, p := range f.Segments {
+		if p.Filesz > 0 {
+			fixed = append(fixed, extent{p.Off, p.Off + p.Filesz})
+		}
+	}
+	index := make(map[*Section]int, len(f.Sections))
+	var movable, loaded []*Section
+	for i, s := range f.Sections {
+		index[s] = i
+		switch {
+		case s.inPlace:
+			fixed = append(fixed, extent{s.Offset, s.Offset + s.origSize})
+		case s.load && (s.dirty || s.added):
+			loaded = append(loaded, s)
+		case s.dirty || s.added || (f.Layout == LayoutCompact && !f.pinned(s)):
+			if !s.dirty && !s.added && s.Type != elf.SHT_NOBITS && !inBounds(s.Offset, s.Size, uint64(len(f.raw))) {
+				return nil, formatErrorf(s.Offset, "sh_offset", "section %s data out of bounds", s.Name)
+			}
+			movable = append(movable, s)
+		case s.Type != elf.SHT_NOBITS:
+			fixed = append(fixed, extent{s.Offset, s.Offset + s.Size})
+		}
+	}
+	// Leave the section header table alone when no section moves, so that
+	// untouched files and in-place overwrites stay byte-identical
+	keepHeaders := len(movable) == 0 && len(loaded) == 0 && len(f.Sections) == f.shnum
+	if keepHeaders {
+		fixed = append(fixed, extent{f.shoff, f.shoff + uint64(f.shnum)*uint64(f.shentsize)})
+	}
+	fixed = mergeExtents(fixed)
+	for _, e := range fixed {
+		plan.keep = max(plan.keep, e.end)
+	}
+	plan.keep = min(plan.keep, uint64(len(f.raw)))
+
+	// Space that may be handed out again
+	var candidates []extent
+	if f.Layout != LayoutAppend {
+		candidates = f.origExtents
+	}
+	free := subtractExtents(mergeExtents(candidates), fixed)
+	for len(free) > 0 && free[len(free)-1].end >= plan.keep {
+		plan.keep = min(plan.keep, free[len(free)-1].off)
+		free = free[:len(free)-1]
+	}
+	plan.free = slices.Clone(free)
+	free = append(free, extent{plan.keep, math.MaxUint64})
+
+	// Place original sections in file order, new ones after them in table order
+	slices.SortStableFunc(movable, func(a, b *Section) int {
+		switch {
+		case a.added != b.added:
+			if a.added {
+				return 1
+			}
+			return -1
+		case a.added:
+			return 0
+		}
+		return cmp.Compare(a.Offset, b.Offset)
+	})
+	end := plan.keep
+	for _, s := range movable {
+		size := s.Size
+		if s.Type == elf.SHT_NOBITS {
+			size = 0
+		}
+		off := allocExtent(free, size, max(f.wordSize(), s.Addralign))
+		plan.offsets[s] = off
+		end = max(end, off+size)
+		if !s.added && !s.dirty && off == s.Offset {
+			continue
+		}
+		plan.addMove(s, index[s], off)
+	}
+	end, err := f.planProgramHeaders(plan, loaded, end)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range loaded {
+		plan.addMove(s, index[s], plan.offsets[s])
+	}
+	slices.SortFunc(plan.Moves, func(a, b SectionMove) int {
+		return cmp.Compare(a.NewOffset, b.NewOffset)
+	})
+
+	plan.SectionHeaderOffset = alignUp(end, f.wordSize())
+	if keepHeaders {
+		plan.SectionHeaderOffset = f.shoff
+	}
+	plan.Size = max(end, plan.SectionHeaderOffset+uint64(len(f.Sections))*uint64(f.sectionHeaderSize()))
+	plan.Size += uint64(len(f.overlay))
+	if err := checkLimit("MaxOutputSize", plan.Size, f.Limits.MaxOutputSize); err != nil {
+		return nil, err
+	}
+	if !plan.fits(f) {
+		return nil, &FormatError{Msg: fmt.Sprintf("layout does not fit into %d bytes; the file claims impossible sizes or alignments", plan.Size)}
+	}
+	return plan, nil
+}
+
+// fits reports whether everything the plan places lies within its size, which
+// fails only when offsets computed from corrupt headers overflowed.
+func (p *LayoutPlan) fits(f *File) bool {
+	ok := p.keep <= p.Size && uint64(len(f.overlay)) <= p.Size &&
+		inBounds(p.SectionHeaderOffset, uint64(len(f.Sections))*uint64(f.sectionHeaderSize()), p.Size) &&
+		inBounds(p.ProgramHeaderOffset, uint64(len(p.progs))*uint64(f.progHeaderSize()), p.Size)
+	for s, off := range p.offsets {
+		ok = ok && (s.Type == elf.SHT_NOBITS || inBounds(off, s.Size, p.Size))
+	}
+	for _, s := range f.Sections {
+		ok = ok && (!s.inPlace || inBounds(s.Offset, s.origSize, p.Size))
+	}
+	return ok
+}
+
+// addMove records that section s at index i is placed at off.
+func (p *LayoutPlan) addMove(s *Section, i int, off uint64) {
+	p.Moves = append(p.Moves, SectionMove{
+		Index:     i,
+		Name:      s.Name,
+		Added:     s.added,
+		OldOffset: s.Offset,
+		OldSize:   s.origSize,
+		NewOffset: off,
+		NewSize:   s.Size,
+	})
+}
+
+// pinned reports whether a section's bytes must stay at their offset because it
+// is allocated or lies within a segment.
+func (f *File) pinned(s *Section) bool {
+	if s.Type == elf.SHT_NULL || s.Flags&elf.SHF_ALLOC != 0 {
+		return true
+	}
+	if s.Type == elf.SHT_NOBITS {
+		return false
+	}
+	for _, p := range f.Segments {
+		if p.Filesz > 0 && s.Offset < p.Off+p.Filesz && s.Offset+s.Size > p.Off {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeExtents sorts extents and joins overlapping or adjacent ones, dropping empty ones.
+func mergeExtents(in []extent) []extent {
+	in = slices.Clone(in)
+	slices.SortFunc(in, func(a, b extent) int {
+		return cmp.Compare(a.off, b.off)
+	})
+	var out []extent
+	for _, e := range in {
+		if e.end <= e.off {
+			continue
+		}
+		if n := len(out); n > 0 && e.off <= out[n-1].end {
+			out[n-1].end = max(out[n-1].end, e.end)
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// subtractExtents removes every byte covered by sub from the merged extents in.
+func subtractExtents(in, sub []extent) []extent {
+	var out []extent
+	for _, e := range in {
+		for _, s := range sub {
+			if s.end <= e.off || s.off >= e.end {
+				continue
+			}
+			if s.off > e.off {
+				out = append(out, extent{e.off, s.off})
+			}
+			e.off = max(e.off, s.end)
+			if e.off >= e.end {
+				break
+			}
+		}
+		if e.off < e.end {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// allocExtent hands out the lowest aligned offset at which size bytes fit into
+// the free list, shrinking the list in place. The last entry must be unbounded.
+func allocExtent(free []extent, size, alignment uint64) uint64 {
+	for i := range free {
+		off := alignUp(free[i].off, alignment)
+		if off > free[i].end || free[i].end-off < size {
+			continue
+		}
+		if size > 0 {
+			free[i].off = off + size
+		}
+		return off
+	}
+	return alignUp(free[len(free)-1].off, alignment)
+}
@@ -0,0 +1,114 @@
+package elfy
+
+import (
+	"bytes"
+	"cmp"
+	"debug/elf"
+	"slices"
+	"testing"
+)
+
+func TestLayoutStrategies(t *testing.T) {
+	b := testBuilders()["x86_64/exec"]
+	b.Sections = append(b.Sections,
+		BuildSection{Name: ".a", Type: elf.SHT_PROGBITS, Addralign: 8, Data: bytes.Repeat([]byte{0xaa}, 64)},
+		BuildSection{Name: ".b", Type: elf.SHT_PROGBITS, Addralign: 8, Data: bytes.Repeat([]byte{0xbb}, 8)},
+	)
+	data, orig := buildFile(t, b)
+	hole := orig.Section(".a").Offset
+	added := bytes.Repeat([]byte{0xcc}, 32)
+
+	for _, layout := range []LayoutStrategy{LayoutReuse, LayoutAppend, LayoutCompact} {
+		t.Run(layout.String(), func(t *testing.T) {
+			f := parseFile(t, data)
+			f.Layout = layout
+			if err := f.RemoveSection(".a"); err != nil {
+				t.Fatal(err)
+			}
+			noFlags := elf.SectionFlag(0)
+			if _, err := f.AddOrReplaceSectionWithOptions(".c", added, SectionOptions{Flags: &noFlags}); err != nil {
+				t.Fatal(err)
+			}
+			plan, err := f.PlanLayout()
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if uint64(len(out)) != plan.Size {
+				t.Errorf("output has %d bytes, plan %d", len(out), plan.Size)
+			}
+			g := parseFile(t, out)
+			checkVerify(t, g)
+
+			// Every section keeps its contents and allocated ones their offset
+			for _, s := range orig.Sections[1:] {
+				if s.Name == ".a" || s.Type == elf.SHT_NOBITS {
+					continue
+				}
+				n := g.Section(s.Name)
+				if n == nil {
+					t.Fatalf("section %s lost", s.Name)
+				}
+				want, _ := s.Data()
+				if got, _ := n.Data(); !bytes.Equal(got, want) && s.Name != ".shstrtab" {
+					t.Errorf("section %s changed contents", s.Name)
+				}
+				if s.Flags&elf.SHF_ALLOC != 0 && n.Offset != s.Offset {
+					t.Errorf("allocated section %s moved from 0x%x to 0x%x", s.Name, s.Offset, n.Offset)
+				}
+			}
+			c := g.Section(".c")
+			if got, _ := c.Data(); !bytes.Equal(got, added) {
+				t.Errorf(".c = %x", got)
+			}
+
+			switch layout {
+			case LayoutReuse:
+				if c.Offset != hole {
+					t.Errorf(".c placed at 0x%x, want the space of .a at 0x%x", c.Offset, hole)
+				}
+			case LayoutAppend:
+				for _, s := range g.Sections {
+					if s != c && s.Type != elf.SHT_NOBITS && s.Offset+s.Size > c.Offset {
+						t.Errorf(".c at 0x%x is not after unchanged section %s ending at 0x%x", c.Offset, s.Name, s.Offset+s.Size)
+					}
+				}
+			case LayoutCompact:
+				// Non-allocated sections are packed without gaps beyond their alignment
+				var loose []*Section
+				for _, s := range g.Sections[1:] {
+					if !g.pinned(s) {
+						loose = append(loose, s)
+					}
+				}
+				slices.SortFunc(loose, func(a, b *Section) int { return cmp.Compare(a.Offset, b.Offset) })
+				for i := 1; i < len(loose); i++ {
+					prev, s := loose[i-1], loose[i]
+					if s.Offset != alignUp(prev.Offset+prev.Size, max(g.wordSize(), s.Addralign)) {
+						t.Errorf("gap between %s ending at 0x%x and %s at 0x%x", prev.Name, prev.Offset+prev.Size, s.Name, s.Offset)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLayoutUnchanged(t *testing.T) {
+	data, f := readFile(t, "tiny64")
+	for _, layout := range []LayoutStrategy{LayoutReuse, LayoutAppend} {
+		f.Layout = layout
+		plan, err := f.PlanLayout()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan.Moves) != 0 || plan.SectionHeaderOffset != plan.OldSectionHeaderOffset {
+			t.Errorf("%s: unedited file plans moves:\n%s", layout, plan)
+		}
+		if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+			t.Errorf("%s: unedited file does not round-trip: %v", layout, err)
+		}
+	}
+}
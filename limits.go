@@ -0,0 +1,41 @@
+package elfy
+
+import (
+	"fmt"
+)
+
+// Limits bounds the resources Parse, Open and Bytes commit to a file, so that
+// untrusted input cannot cause huge allocations. A zero field means no limit.
+type Limits struct {
+	MaxFileSize   uint64 // Largest input accepted, in bytes
+	MaxSections   int    // Largest number of section headers accepted
+	MaxSegments   int    // Largest number of program headers accepted
+	MaxOutputSize uint64 // Largest output produced by Bytes, in bytes
+}
+
+// DefaultLimits are the limits applied by Parse and Open.
+var DefaultLimits = Limits{
+	MaxFileSize:   4 << 30,
+	MaxSections:   1 << 20,
+	MaxSegments:   1 << 16,
+	MaxOutputSize: 4 << 30,
+}
+
+// LimitError reports a file that exceeds one of its Limits.
+type LimitError struct {
+	Limit string // Name of the exceeded Limits field
+	Value uint64 // Size or count found
+	Max   uint64 // Configured limit
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %d exceeds limit of %d", e.Limit, e.Value, e.Max)
+}
+
+// checkLimit returns a *LimitError if value exceeds max, unless max is zero.
+func checkLimit(name string, value, max uint64) error {
+	if max != 0 && value > max {
+		return &LimitError{Limit: name, Value: value, Max: max}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package elfy
+
+import (
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+// planProgramHeaders lays out the program header table and places the sections
+// marked for loading into a new PT_LOAD segment that starts on a page boundary
+// at or after end and is mapped past the highest existing segment. If the table
+// needs more entries than fit at its current offset, PT_NULL entries are dropped
+// first, then the table grows in place when the bytes after it are unused, and
+// otherwise it is moved into a new PT_LOAD segment the way patchelf does.
+// Kernels before 5.18 derive AT_PHDR from e_phoff and the first PT_LOAD, so a
+// moved table is placed at the same distance between address and offset as the
+// first PT_LOAD. PT_PHDR is updated to describe the final table. The addresses
+// of the loaded sections are stored in their headers.
+//
+// Returns:
+//   - The end offset of everything placed so far.
+//   - An error if a new segment is needed but cannot be mapped.
+func (f *File) planProgramHeaders(plan *LayoutPlan, loaded []*Section, end uint64) (uint64, error) {
+	progs := make([]elf.ProgHeader, 0, len(f.Segments)+2)
+	for _, seg := range f.Segments {
+		progs = append(progs, seg.ProgHeader)
+	}
+	entSize := uint64(f.progHeaderSize())
+	newLoad := len(loaded) > 0
+	need := len(progs)
+	if newLoad {
+		need++
+	}
+	for need > int(f.phnum) {
+		i := slices.IndexFunc(progs, func(p elf.ProgHeader) bool { return p.Type == elf.PT_NULL })
+		if i == -1 {
+			break
+		}
+		progs = slices.Delete(progs, i, i+1)
+		need--
+	}
+
+	var maxVaddr uint64
+	firstLoad, lastLoad := -1, -1
+	for i, p := range progs {
+		if p.Type == elf.PT_LOAD {
+			maxVaddr = max(maxVaddr, p.Vaddr+p.Memsz)
+			if firstLoad == -1 {
+				firstLoad = i
+			}
+			lastLoad = i
+		}
+	}
+	relocate := need > int(f.phnum) && !f.phtCanGrow(plan, need-int(f.phnum))
+	if relocate && !newLoad && lastLoad == -1 {
+		// Nothing is mapped, so the table only needs room in the file
+		plan.ProgramHeaderOffset = alignUp(end, f.wordSize())
+		end = plan.ProgramHeaderOffset + uint64(need)*entSize
+		relocate = false
+	} else if relocate && !newLoad {
+		// The moved table has to be mapped by a segment of its own
+		newLoad = true
+		need++
+	}
+	if newLoad && lastLoad == -1 {
+		return 0, fmt.Errorf("%w: file has no PT_LOAD segment to extend", ErrInvalidArgument)
+	}
+
+	if newLoad {
+		page := f.pageSize()
+		seg := elf.ProgHeader{Type: elf.PT_LOAD, Flags: elf.PF_R, Align: page}
+		for _, s := range loaded {
+			if s.Flags&elf.SHF_WRITE != 0 {
+				seg.Flags |= elf.PF_W
+			}
+			if s.Flags&elf.SHF_EXECINSTR != 0 {
+				seg.Flags |= elf.PF_X
+			}
+		}
+
+		start, vaddr := alignUp(end, page), alignUp(maxVaddr, page)
+		if relocate {
+			first := progs[firstLoad]
+			if first.Vaddr < first.Off {
+				return 0, fmt.Errorf("%w: first PT_LOAD segment maps offset 0x%x below it at 0x%x, cannot move the program headers", ErrInvalidArgument, first.Off, first.Vaddr)
+			}
+			vaddr = start + first.Vaddr - first.Off
+			if vaddr < maxVaddr {
+				shift := alignUp(maxVaddr-vaddr, page)
+				start, vaddr = start+shift, vaddr+shift
+			}
+		}
+		cursor := start
+		if relocate {
+			plan.ProgramHeaderOffset = start
+			cursor += uint64(need) * entSize
+		}
+		for _, s := range loaded {
+			cursor = alignUp(cursor, max(1, s.Addralign))
+			plan.offsets[s] = cursor
+			cursor += s.Size
+		}
+
+		seg.Off = start
+		seg.Vaddr, seg.Paddr = vaddr, vaddr
+		seg.Filesz, seg.Memsz = cursor-start, cursor-start
+		for _, s := range loaded {
+			s.Addr = vaddr + plan.offsets[s] - start
+		}
+		progs = slices.Insert(progs, lastLoad+1, seg)
+		plan.AddedSegment = &seg
+		end = cursor
+	}
+
+	phtSize := uint64(len(progs)) * entSize
+	for i := range progs {
+		if progs[i].Type != elf.PT_PHDR {
+			continue
+		}
+		if relocate {
+			seg := plan.AddedSegment
+			progs[i].Off = seg.Off
+			progs[i].Vaddr, progs[i].Paddr = seg.Vaddr, seg.Vaddr
+		}
+		progs[i].Filesz, progs[i].Memsz = phtSize, phtSize
+	}
+	if len(progs) == 0 {
+		plan.ProgramHeaderOffset = 0
+	}
+	plan.progs = progs
+	return end, nil
+}
+
+// phtCanGrow reports whether extra more program headers fit directly after the
+// current table without overlapping any section, inside the segment that maps the table.
+func (f *File) phtCanGrow(plan *LayoutPlan, extra int) bool {
+	if f.phnum == 0 {
+		return false
+	}
+	end := f.phoff + uint64(f.phnum)*uint64(f.progHeaderSize())
+	grown := extent{end, end + uint64(extra)*uint64(f.progHeaderSize())}
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOBITS || s.Size == 0 {
+			continue
+		}
+		off, moved := plan.offsets[s]
+		if !moved {
+			off = s.Offset
+		}
+		if off < grown.end && off+s.Size > grown.off {
+			return false
+		}
+	}
+	for _, p := range f.Segments {
+		if p.Type == elf.PT_LOAD && p.Off <= f.phoff && grown.end <= p.Off+p.Filesz {
+			return true
+		}
+	}
+	return false
+}
+
+// pageSize returns the page size used to align new segments: the largest
+// alignment of any PT_LOAD segment, and at least 4 KiB.
+func (f *File) pageSize() uint64 {
+	page := uint64(0x1000)
+	for _, p := range f.Segments {
+		if p.Type == elf.PT_LOAD {
+			page = max(page, p.Align)
+		}
+	}
+	return page
+}
@@ -0,0 +1,128 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"testing"
+)
+
+func TestLoadSection(t *testing.T) {
+	// All files have sections right after their program headers, so the table moves
+	files := map[string]func(t *testing.T) *File{
+		"tiny64": func(t *testing.T) *File {
+			_, f := readFile(t, "tiny64")
+			return f
+		},
+		"x86_64": func(t *testing.T) *File {
+			_, f := buildFile(t, testBuilders()["x86_64/exec"])
+			return f
+		},
+		"i386": func(t *testing.T) *File {
+			_, f := buildFile(t, testBuilders()["i386/exec"])
+			return f
+		},
+	}
+	for name, open := range files {
+		t.Run(name, func(t *testing.T) {
+			f := open(t)
+			phnum := len(f.Segments)
+			payload := []byte("loaded payload")
+			if _, err := f.AddOrReplaceSectionWithOptions(".payload", payload, SectionOptions{Load: true}); err != nil {
+				t.Fatal(err)
+			}
+			plan, err := f.PlanLayout()
+			if err != nil {
+				t.Fatal(err)
+			}
+			seg := plan.AddedSegment
+			if seg == nil {
+				t.Fatal("no PT_LOAD segment added")
+			}
+			page := f.pageSize()
+			if seg.Type != elf.PT_LOAD || seg.Off%page != 0 || seg.Vaddr%page != seg.Off%page || seg.Align != page {
+				t.Errorf("added segment = %+v, want PT_LOAD aligned to 0x%x", *seg, page)
+			}
+			if plan.ProgramHeaderOffset != seg.Off {
+				t.Errorf("program headers at 0x%x, want the start of the added segment at 0x%x", plan.ProgramHeaderOffset, seg.Off)
+			}
+			// The address is known once planned, before anything is written
+			s := f.Section(".payload")
+			if info := f.SectionTable()[s.Index()]; info.Addr == 0 || info.Addr-seg.Vaddr != plan.offsets[s]-seg.Off {
+				t.Errorf("SectionTable address of .payload = 0x%x, want it inside the segment at 0x%x", info.Addr, seg.Vaddr)
+			}
+
+			out, err := f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			g := parseFile(t, out)
+			checkVerify(t, g)
+			if len(g.Segments) != phnum+1 {
+				t.Errorf("%d segments, want %d", len(g.Segments), phnum+1)
+			}
+			n := g.Section(".payload")
+			if n.Flags&elf.SHF_ALLOC == 0 || n.Offset < seg.Off || n.Offset+n.Size > seg.Off+seg.Filesz || n.Addr-seg.Vaddr != n.Offset-seg.Off || n.Addr != s.Addr {
+				t.Errorf(".payload at offset 0x%x address 0x%x is not mapped by %+v", n.Offset, n.Addr, *seg)
+			}
+
+			ef, err := elf.NewFile(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var phdr, first *elf.Prog
+			for _, p := range ef.Progs {
+				if p.Type == elf.PT_PHDR {
+					phdr = p
+				}
+				if p.Type == elf.PT_LOAD && first == nil {
+					first = p
+				}
+			}
+			if phdr == nil {
+				t.Fatal("PT_PHDR lost")
+			}
+			size := uint64(len(ef.Progs)) * uint64(f.progHeaderSize())
+			if phdr.Off != plan.ProgramHeaderOffset || phdr.Vaddr != seg.Vaddr || phdr.Filesz != size || phdr.Memsz != size {
+				t.Errorf("PT_PHDR = %+v, want the %d-byte table at 0x%x", phdr.ProgHeader, size, plan.ProgramHeaderOffset)
+			}
+			// Kernels before 5.18 set AT_PHDR to e_phoff relative to the first PT_LOAD
+			if atPhdr := first.Vaddr - first.Off + phdr.Off; atPhdr != phdr.Vaddr {
+				t.Errorf("AT_PHDR of old kernels = 0x%x, PT_PHDR maps the table at 0x%x", atPhdr, phdr.Vaddr)
+			}
+			if got, err := ef.Section(".payload").Data(); err != nil || !bytes.Equal(got, payload) {
+				t.Errorf(".payload = %q, %v", got, err)
+			}
+		})
+	}
+}
+
+func TestLoadMappedSection(t *testing.T) {
+	data, f := readFile(t, "tiny64")
+	load := SectionOptions{Load: true}
+	if _, err := f.AddOrReplaceSectionWithOptions(".rodata", []byte{1, 2, 3, 4}, load); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("replacing .rodata with Load: error = %v, want %v", err, ErrInvalidArgument)
+	}
+	if err := f.UpdateSection(".bss", load); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("loading .bss: error = %v, want %v", err, ErrInvalidArgument)
+	}
+	if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("refused loads changed the file: %v", err)
+	}
+
+	// A section outside the memory image can be loaded, twice without harm
+	for range 2 {
+		if err := f.UpdateSection(".comment", load); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := parseFile(t, out)
+	checkVerify(t, g)
+	if len(g.Segments) != len(f.Segments)+1 || g.Section(".comment").Flags&elf.SHF_ALLOC == 0 {
+		t.Errorf("loading .comment gave %d segments and flags %v", len(g.Segments), g.Section(".comment").Flags)
+	}
+}
@@ -0,0 +1,412 @@
+package elfy
+
+import (
+	"bytes"
+	"cmp"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Note is a single record of an SHT_NOTE section or PT_NOTE segment.
+type Note struct {
+	Name    string // Owner, e.g. "GNU"
+	Type    uint32
+	Desc    []byte
+	Section string // SHT_NOTE section holding the note, or "" if it is only covered by a segment
+	Segment int    // Index of the PT_NOTE segment covering the note, or -1
+	Offset  uint64 // File offset of the record, relative to the section's parsed offset if it was edited
+	Align   uint64 // Record alignment: 4, or 8 for sections and segments aligned to 8 bytes
+}
+
+// noteTypes names well-known note types by owner.
+var noteTypes = []struct {
+	owner string
+	typ   uint32
+	name  string
+}{
+	{"GNU", 1, "NT_GNU_ABI_TAG"},
+	{"GNU", 2, "NT_GNU_HWCAP"},
+	{"GNU", 3, "NT_GNU_BUILD_ID"},
+	{"GNU", 4, "NT_GNU_GOLD_VERSION"},
+	{"GNU", 5, "NT_GNU_PROPERTY_TYPE_0"},
+	{"FDO", 0xcafe1a7e, "NT_FDO_PACKAGING_METADATA"},
+	{"Go", 4, "NT_GO_BUILD_ID"},
+	{"stapsdt", 3, "NT_STAPSDT"},
+}
+
+// NoteTypeName returns the name of a well-known note type of the given owner,
+// e.g. "NT_GNU_BUILD_ID", or "" if it is not known.
+func NoteTypeName(owner string, typ uint32) string {
+	for _, t := range noteTypes {
+		if t.owner == owner && t.typ == typ {
+			return t.name
+		}
+	}
+	return ""
+}
+
+// ParseNoteType parses a note type given by name, with or without the NT_
+// prefix and in any case (e.g. "gnu_build_id"), or as a number.
+func ParseNoteType(s string) (uint32, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	for _, t := range noteTypes {
+		if t.name == name || t.name == "NT_"+name {
+			return t.typ, nil
+		}
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unknown note type %q", ErrInvalidArgument, s)
+	}
+	return uint32(n), nil
+}
+
+// Notes returns every note of the file: first the records of each SHT_NOTE
+// section in table order, then those of PT_NOTE segments that no section
+// covers. Notes found in both carry the section and the segment.
+//
+// Returns:
+//   - The notes in file order within each section or segment.
+//   - An error if a note section or segment is malformed.
+func (f *File) Notes() ([]Note, error) {
+	// sectionNotes records where the notes of a section sit in notes, so
+	// that segments are matched by the file range the section was parsed
+	// from rather than by record offsets, which change with its contents
+	type sectionNotes struct {
+		s           *Section
+		first, last int
+	}
+	var notes []Note
+	var parsed []sectionNotes
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOTE {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, err
+		}
+		found, err := parseNotes(f.ByteOrder, data, noteAlign(s.Addralign), s.Offset)
+		if err != nil {
+			return nil, fmt.Errorf("section %s: %w", s.Name, err)
+		}
+		for i := range found {
+			found[i].Section = s.Name
+		}
+		parsed = append(parsed, sectionNotes{s, len(notes), len(notes) + len(found)})
+		notes = append(notes, found...)
+	}
+	for i, p := range f.Segments {
+		if p.Type != elf.PT_NOTE || p.Filesz == 0 {
+			continue
+		}
+		end := p.Off + p.Filesz
+		if !inBounds(p.Off, p.Filesz, uint64(len(f.raw))) {
+			return nil, formatErrorf(p.Off, "p_offset", "segment %d out of bounds", i)
+		}
+		// Only the parts of the segment outside its note sections are read
+		// from the file, since the sections may have been edited
+		var covered []*Section
+		for _, sn := range parsed {
+			if sn.s.added || sn.s.Offset < p.Off || sn.s.Offset >= end {
+				continue
+			}
+			covered = append(covered, sn.s)
+			for j := sn.first; j < sn.last; j++ {
+				if notes[j].Segment == -1 {
+					notes[j].Segment = i
+				}
+			}
+		}
+		slices.SortFunc(covered, func(a, b *Section) int { return cmp.Compare(a.Offset, b.Offset) })
+		off := p.Off
+		for _, s := range append(covered, nil) {
+			next := end
+			if s != nil {
+				next = s.Offset
+			}
+			if next > off {
+				found, err := parseNotes(f.ByteOrder, f.raw[off:next], noteAlign(p.Align), off)
+				if err != nil {
+					return nil, fmt.Errorf("segment %d: %w", i, err)
+				}
+				for _, n := range found {
+					n.Segment = i
+					notes = append(notes, n)
+				}
+			}
+			if s != nil {
+				off = max(off, min(s.Offset+s.Size, end))
+			}
+		}
+	}
+	return notes, nil
+}
+
+// AddOrReplaceNote stores a note in an SHT_NOTE section, replacing the first
+// note with the same owner and type and keeping every other note. A missing
+// section is created as a non-allocated SHT_NOTE section. Notes of sections
+// that are allocated or covered by a segment are rewritten in place, so they
+// must not grow, nor shrink when more notes follow in the same PT_NOTE
+// segment; segments that end with the section are resized with it.
+//
+// Parameters:
+//   - section: The name of the note section; if empty, the section that already holds a matching note.
+//   - name: The owner of the note, e.g. "GNU".
+//   - typ: The note type.
+//   - desc: The note descriptor.
+//
+// Returns:
+//   - An error if the section is not a note section or the notes do not fit.
+func (f *File) AddOrReplaceNote(section, name string, typ uint32, desc []byte) error {
+	if section == "" {
+		notes, err := f.Notes()
+		if err != nil {
+			return err
+		}
+		i := slices.IndexFunc(notes, func(n Note) bool { return n.Name == name && n.Type == typ && n.Section != "" })
+		if i == -1 {
+			return fmt.Errorf("%w: no section given for new note %s", ErrInvalidArgument, name)
+		}
+		section = notes[i].Section
+	}
+	s := f.Section(section)
+	if s == nil {
+		var err error
+		if s, err = f.AddSection(section, nil); err != nil {
+			return err
+		}
+		s.Type, s.Flags, s.Addralign = elf.SHT_NOTE, 0, 4
+	}
+	if s.Type != elf.SHT_NOTE {
+		return fmt.Errorf("%w: section %s is not of type SHT_NOTE", ErrInvalidArgument, section)
+	}
+	notes, err := s.notes()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(notes, func(n Note) bool { return n.Name == name && n.Type == typ })
+	if i == -1 {
+		notes = append(notes, Note{Name: name, Type: typ, Desc: desc})
+	} else {
+		notes[i].Desc = desc
+	}
+	return s.setNotes(notes)
+}
+
+// RemoveNote removes every note with the given owner and type from an SHT_NOTE
+// section, keeping the section itself. Allocated sections shrink in place,
+// with the restrictions of AddOrReplaceNote.
+//
+// Parameters:
+//   - section: The name of the note section; if empty, every SHT_NOTE section.
+//   - name: The owner of the notes to remove.
+//   - typ: The type of the notes to remove.
+//
+// Returns:
+//   - An error if no matching note is found.
+func (f *File) RemoveNote(section, name string, typ uint32) error {
+	var targets []*Section
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOTE && (section == "" || s.Name == section) {
+			targets = append(targets, s)
+		}
+	}
+	if section != "" && len(targets) == 0 {
+		if f.Section(section) == nil {
+			return fmt.Errorf("%w: %s", ErrSectionNotFound, section)
+		}
+		return fmt.Errorf("%w: section %s is not of type SHT_NOTE", ErrInvalidArgument, section)
+	}
+	removed := false
+	for _, s := range targets {
+		notes, err := s.notes()
+		if err != nil {
+			return err
+		}
+		kept := slices.DeleteFunc(slices.Clone(notes), func(n Note) bool { return n.Name == name && n.Type == typ })
+		if len(kept) == len(notes) {
+			continue
+		}
+		if err := s.setNotes(kept); err != nil {
+			return err
+		}
+		removed = true
+	}
+	if !removed {
+		return fmt.Errorf("%w: %s type %d", ErrNoteNotFound, name, typ)
+	}
+	return nil
+}
+
+// notes parses the records of an SHT_NOTE section.
+func (s *Section) notes() ([]Note, error) {
+	data, err := s.Data()
+	if err != nil {
+		return nil, err
+	}
+	notes, err := parseNotes(s.file.ByteOrder, data, noteAlign(s.Addralign), s.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("section %s: %w", s.Name, err)
+	}
+	return notes, nil
+}
+
+// setNotes replaces the records of an SHT_NOTE section. Sections that must
+// stay at their offset are overwritten in place and PT_NOTE segments that
+// end with the section follow its new size.
+func (s *Section) setNotes(notes []Note) error {
+	var data []byte
+	for _, n := range notes {
+		data = appendNote(s.file.ByteOrder, data, n.Name, n.Type, n.Desc, noteAlign(s.Addralign))
+	}
+	moved := s.added || (s.dirty && !s.inPlace)
+	if moved || !s.file.pinned(s) {
+		s.SetData(data)
+		return nil
+	}
+	size := s.Size
+	if uint64(len(data)) < size {
+		for _, p := range s.file.Segments {
+			if p.Type == elf.PT_NOTE && p.Off <= s.Offset && p.Off+p.Filesz > s.Offset+size {
+				return fmt.Errorf("%w: notes of section %s must keep their size of %d bytes since more notes follow in the same segment", ErrDoesNotFit, s.Name, size)
+			}
+		}
+	}
+	if err := s.SetDataInPlace(data, false); err != nil {
+		return fmt.Errorf("notes of allocated section %s cannot grow: %w", s.Name, err)
+	}
+	for _, p := range s.file.Segments {
+		if p.Type == elf.PT_NOTE && p.Off <= s.Offset && p.Off+p.Filesz == s.Offset+size {
+			p.Filesz = s.Offset + s.Size - p.Off
+			p.Memsz = p.Filesz
+		}
+	}
+	return nil
+}
+
+// parseNotes decodes the note records in data, which starts at file offset base.
+// Trailing bytes too short for a note header are ignored as padding; an
+// all-zero header is a valid note without name or descriptor.
+func parseNotes(order binary.ByteOrder, data []byte, align, base uint64) ([]Note, error) {
+	var notes []Note
+	size := uint64(len(data))
+	for off := uint64(0); off+12 <= size; {
+		namesz := uint64(order.Uint32(data[off:]))
+		descsz := uint64(order.Uint32(data[off+4:]))
+		typ := order.Uint32(data[off+8:])
+		if !inBounds(off+12, namesz, size) {
+			return nil, formatErrorf(base+off, "n_namesz", "note name of %d bytes out of bounds", namesz)
+		}
+		descOff := off + alignUp(12+namesz, align)
+		if !inBounds(descOff, descsz, size) {
+			return nil, formatErrorf(base+off, "n_descsz", "note descriptor of %d bytes out of bounds", descsz)
+		}
+		notes = append(notes, Note{
+			Name:    string(bytes.TrimRight(data[off+12:off+12+namesz], "\x00")),
+			Type:    typ,
+			Desc:    append([]byte(nil), data[descOff:descOff+descsz]...),
+			Segment: -1,
+			Offset:  base + off,
+			Align:   align,
+		})
+		off = alignUp(descOff+descsz-off, align) + off
+	}
+	return notes, nil
+}
+
+// noteAlign returns the alignment of the records in a note section or
+// segment: 8 for those aligned to 8 bytes, such as .note.gnu.property, and 4
+// otherwise.
+func noteAlign(align uint64) uint64 {
+	if align == 8 {
+		return 8
+	}
+	return 4
+}
+
+// appendNote encodes a note record with the given alignment and appends it to
+// buf, which must hold whole records. The name is NUL-terminated unless empty.
+func appendNote(order binary.ByteOrder, buf []byte, name string, typ uint32, desc []byte, align uint64) []byte {
+	start := uint64(len(buf))
+	namesz := uint32(0)
+	if name != "" {
+		namesz = uint32(len(name) + 1)
+	}
+	var hdr [12]byte
+	order.PutUint32(hdr[0:], namesz)
+	order.PutUint32(hdr[4:], uint32(len(desc)))
+	order.PutUint32(hdr[8:], typ)
+	buf = append(buf, hdr[:]...)
+	if name != "" {
+		buf = append(append(buf, name...), 0)
+	}
+	buf = append(buf, make([]byte, alignUp(uint64(len(buf))-start, align)-(uint64(len(buf))-start))...)
+	buf = append(buf, desc...)
+	return append(buf, make([]byte, alignUp(uint64(len(buf))-start, align)-(uint64(len(buf))-start))...)
+}
+
+// ListNotes returns every note present in the provided ELF data; see File.Notes.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//
+// Returns:
+//   - A slice of Note.
+//   - An error if the ELF data is invalid or a note section or segment is malformed.
+func ListNotes(elfData []byte) ([]Note, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	return f.Notes()
+}
+
+// AddOrReplaceNote stores a note in the ELF data; see File.AddOrReplaceNote.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//   - section: The name of the note section.
+//   - name: The owner of the note, e.g. "GNU".
+//   - typ: The note type.
+//   - desc: The note descriptor.
+//
+// Returns:
+//   - A byte slice containing the modified ELF file data.
+//   - An error if the ELF data is invalid or the note cannot be stored.
+func AddOrReplaceNote(elfData []byte, section, name string, typ uint32, desc []byte) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.AddOrReplaceNote(section, name, typ, desc); err != nil {
+		return nil, err
+	}
+	return f.Bytes()
+}
+
+// RemoveNote removes notes from the ELF data; see File.RemoveNote.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//   - section: The name of the note section, or "" for every note section.
+//   - name: The owner of the notes to remove.
+//   - typ: The type of the notes to remove.
+//
+// Returns:
+//   - A byte slice containing the modified ELF file data.
+//   - An error if the ELF data is invalid or no matching note is found.
+func RemoveNote(elfData []byte, section, name string, typ uint32) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.RemoveNote(section, name, typ); err != nil {
+		return nil, err
+	}
+	return f.Bytes()
+}
@@ -0,0 +1,116 @@
+package elfy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestNotes(t *testing.T) {
+	_, f := readFile(t, "tiny64")
+	id := bytes.Repeat([]byte{0xab}, 20)
+	if err := f.AddOrReplaceNote("", "GNU", 3, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RemoveNote("", "GNU", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.AddOrReplaceNote(".note.package", "FDO", 0xcafe1a7e, []byte(`{"type":"rpm"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.RemoveNote("", "GNU", 1); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("second removal: error = %v, want %v", err, ErrNoteNotFound)
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := parseFile(t, out)
+	checkVerify(t, g)
+	notes, err := g.Notes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]Note)
+	for _, n := range notes {
+		got[NoteTypeName(n.Name, n.Type)] = n
+	}
+	if n := got["NT_GNU_BUILD_ID"]; !bytes.Equal(n.Desc, id) || n.Section != ".note.gnu.build-id" || n.Segment == -1 {
+		t.Errorf("build ID note = %+v", n)
+	}
+	if n, ok := got["NT_GNU_ABI_TAG"]; ok {
+		t.Errorf("removed note still present: %+v", n)
+	}
+	if n := got["NT_FDO_PACKAGING_METADATA"]; string(n.Desc) != `{"type":"rpm"}` || n.Section != ".note.package" {
+		t.Errorf("packaging note = %+v", n)
+	}
+	if _, ok := got["NT_GNU_PROPERTY_TYPE_0"]; !ok || len(notes) != 3 {
+		t.Errorf("got %d notes: %+v", len(notes), notes)
+	}
+}
+
+func TestNotesEditedInPlace(t *testing.T) {
+	b := testBuilders()["x86_64/exec"]
+	// An empty note is a valid record, not padding, when more notes follow
+	b.Notes = append([]BuildNote{{Section: ".note.test"}}, b.Notes...)
+	_, f := buildFile(t, b)
+	if notes, err := f.Notes(); err != nil || len(notes) != 2 {
+		t.Fatalf("notes = %+v, %v; want 2 notes", notes, err)
+	}
+	// Shrinking the section moves the elfy note to another offset
+	if err := f.RemoveNote(".note.test", "", 0); err != nil {
+		t.Fatal(err)
+	}
+	notes, err := f.Notes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes) != 1 || notes[0].Name != "elfy" || notes[0].Section != ".note.test" || notes[0].Segment == -1 {
+		t.Errorf("notes after removal = %+v", notes)
+	}
+}
+
+func TestParseNotes(t *testing.T) {
+	le := binary.LittleEndian
+	gnu := appendNote(le, nil, "GNU", 1, []byte{1, 2, 3, 4}, 4)
+	empty := make([]byte, 12)
+	tests := []struct {
+		name  string
+		data  []byte
+		want  int
+		check bool
+	}{
+		{"all-zero final note", append(slices.Clone(gnu), empty...), 2, false},
+		{"only an all-zero note", empty, 1, false},
+		{"short padding", append(slices.Clone(gnu), 0, 0, 0, 0, 0, 0, 0, 0), 1, false},
+		{"name out of bounds", gnu[:14], 0, true},
+		{"descriptor out of bounds", gnu[:len(gnu)-1], 0, true},
+	}
+	for _, tt := range tests {
+		notes, err := parseNotes(le, tt.data, 4, 0x100)
+		var formatErr *FormatError
+		if tt.check {
+			if !errors.As(err, &formatErr) || formatErr.Offset != 0x100 {
+				t.Errorf("%s: error = %v, want a *FormatError at 0x100", tt.name, err)
+			}
+			continue
+		}
+		if err != nil || len(notes) != tt.want {
+			t.Errorf("%s: %d notes, %v; want %d", tt.name, len(notes), err, tt.want)
+			continue
+		}
+		if last := notes[len(notes)-1]; tt.want == 2 && (last.Name != "" || last.Type != 0 || len(last.Desc) != 0 || last.Offset != 0x100+uint64(len(gnu))) {
+			t.Errorf("%s: last note = %+v", tt.name, last)
+		}
+	}
+
+	// The empty note survives a round trip through the builder and Notes
+	b := testBuilders()["x86_64/exec"]
+	b.Notes = append(b.Notes, BuildNote{Section: ".note.test"})
+	_, f := buildFile(t, b)
+	if notes, err := f.Notes(); err != nil || len(notes) != 2 || notes[1].Name != "" {
+		t.Errorf("notes = %+v, %v; want the elfy note and an empty one", notes, err)
+	}
+}
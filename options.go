@@ -0,0 +1,229 @@
+package elfy
+
+import (
+	"debug/elf"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SectionOptions selects header attributes to set when adding or updating a section.
+// Nil fields are left alone: new sections keep the defaults of AddSection
+// (SHT_PROGBITS, SHF_ALLOC, alignment 1) and existing sections keep their current values.
+type SectionOptions struct {
+	Type      *elf.SectionType
+	Flags     *elf.SectionFlag
+	Addr      *uint64
+	Addralign *uint64
+	Entsize   *uint64
+	Link      *uint32
+	Info      *uint32
+
+	// Load makes the section part of the memory image: it is marked SHF_ALLOC,
+	// given an address past the highest segment and covered by a new PT_LOAD
+	// segment when the file is written. See File.PlanLayout. Sections that a
+	// PT_LOAD segment already maps cannot be loaded again.
+	Load bool
+}
+
+// Apply sets the header attributes selected by opts on the section.
+// A section that is not allocated and whose offset no longer satisfies its new
+// alignment is moved when the file is written.
+//
+// Parameters:
+//   - opts: The attributes to set.
+//
+// Returns:
+//   - An error if the options are invalid.
+func (s *Section) Apply(opts SectionOptions) error {
+	if err := opts.validate(s.file); err != nil {
+		return err
+	}
+	if opts.Load && s.file.mapped(s) {
+		return fmt.Errorf("%w: section %s is already mapped by a PT_LOAD segment", ErrInvalidArgument, s.Name)
+	}
+	if opts.Type != nil {
+		s.Type = *opts.Type
+	}
+	if opts.Flags != nil {
+		s.Flags = *opts.Flags
+	}
+	if opts.Addr != nil {
+		s.Addr = *opts.Addr
+	}
+	if opts.Addralign != nil {
+		s.Addralign = *opts.Addralign
+	}
+	if opts.Entsize != nil {
+		s.Entsize = *opts.Entsize
+	}
+	if opts.Link != nil {
+		s.Link = *opts.Link
+	}
+	if opts.Info != nil {
+		s.Info = *opts.Info
+	}
+	if opts.Load {
+		s.Flags |= elf.SHF_ALLOC
+		s.load = true
+		if !s.dirty && !s.added {
+			data, err := s.Data()
+			if err != nil {
+				return err
+			}
+			s.SetData(data)
+		}
+	}
+
+	if !s.dirty && !s.added && s.Type != elf.SHT_NOBITS && !s.file.pinned(s) && alignUp(s.Offset, s.Addralign) != s.Offset {
+		data, err := s.Data()
+		if err != nil {
+			return err
+		}
+		s.SetData(data)
+	}
+	return nil
+}
+
+// AddOrReplaceSectionWithOptions adds a new section or replaces the contents of
+// an existing one, then applies the header attributes selected by opts.
+//
+// Parameters:
+//   - name: The name of the section to add or replace.
+//   - data: The raw bytes to write as the section's content.
+//   - opts: The header attributes to set.
+//
+// Returns:
+//   - A pointer to the added or replaced Section.
+//   - An error if the section cannot be added or the options are invalid.
+func (f *File) AddOrReplaceSectionWithOptions(name string, data []byte, opts SectionOptions) (*Section, error) {
+	if err := opts.validate(f); err != nil {
+		return nil, err
+	}
+	if s := f.Section(name); s != nil && opts.Load && f.mapped(s) {
+		return nil, fmt.Errorf("%w: section %s is already mapped by a PT_LOAD segment", ErrInvalidArgument, name)
+	}
+	s, err := f.AddOrReplaceSection(name, data)
+	if err != nil {
+		return nil, err
+	}
+	return s, s.Apply(opts)
+}
+
+// UpdateSection changes the header attributes of an existing section without touching its contents.
+//
+// Parameters:
+//   - name: The name of the section to update.
+//   - opts: The attributes to set.
+//
+// Returns:
+//   - An error if the section is not found or the options are invalid.
+func (f *File) UpdateSection(name string, opts SectionOptions) error {
+	s := f.Section(name)
+	if s == nil {
+		return fmt.Errorf("%w: %s", ErrSectionNotFound, name)
+	}
+	return s.Apply(opts)
+}
+
+// mapped reports whether the parsed section s is allocated and lies inside a
+// PT_LOAD segment of the file.
+func (f *File) mapped(s *Section) bool {
+	if s.added || s.Flags&elf.SHF_ALLOC == 0 {
+		return false
+	}
+	parsed := *s
+	parsed.Size = s.origSize
+	for _, p := range f.Segments {
+		if p.Type == elf.PT_LOAD && f.sectionInSegment(&parsed, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the selected attributes against the file they will be applied to.
+func (opts SectionOptions) validate(f *File) error {
+	if opts.Addralign != nil && *opts.Addralign > 1 && *opts.Addralign&(*opts.Addralign-1) != 0 {
+		return fmt.Errorf("%w: section alignment %d is not a power of two", ErrInvalidArgument, *opts.Addralign)
+	}
+	if opts.Link != nil && int(*opts.Link) >= len(f.Sections) {
+		return fmt.Errorf("%w: sh_link %d out of range", ErrInvalidArgument, *opts.Link)
+	}
+	if opts.Load {
+		if len(f.Segments) == 0 {
+			return fmt.Errorf("%w: cannot load sections of a file without program headers", ErrInvalidArgument)
+		}
+		if opts.Type != nil && *opts.Type == elf.SHT_NOBITS {
+			return fmt.Errorf("%w: cannot load SHT_NOBITS sections into a new segment", ErrInvalidArgument)
+		}
+	}
+	return nil
+}
+
+// sectionTypes lists the section types accepted by ParseSectionType.
+var sectionTypes = []elf.SectionType{
+	elf.SHT_NULL, elf.SHT_PROGBITS, elf.SHT_SYMTAB, elf.SHT_STRTAB, elf.SHT_RELA,
+	elf.SHT_HASH, elf.SHT_DYNAMIC, elf.SHT_NOTE, elf.SHT_NOBITS, elf.SHT_REL,
+	elf.SHT_SHLIB, elf.SHT_DYNSYM, elf.SHT_INIT_ARRAY, elf.SHT_FINI_ARRAY,
+	elf.SHT_PREINIT_ARRAY, elf.SHT_GROUP, elf.SHT_SYMTAB_SHNDX,
+	elf.SHT_GNU_ATTRIBUTES, elf.SHT_GNU_HASH, elf.SHT_GNU_LIBLIST,
+	elf.SHT_GNU_VERDEF, elf.SHT_GNU_VERNEED, elf.SHT_GNU_VERSYM,
+}
+
+// sectionFlags lists the section flags accepted by ParseSectionFlags.
+var sectionFlags = []elf.SectionFlag{
+	elf.SHF_WRITE, elf.SHF_ALLOC, elf.SHF_EXECINSTR, elf.SHF_MERGE, elf.SHF_STRINGS,
+	elf.SHF_INFO_LINK, elf.SHF_LINK_ORDER, elf.SHF_OS_NONCONFORMING, elf.SHF_GROUP,
+	elf.SHF_TLS, elf.SHF_COMPRESSED,
+}
+
+// ParseSectionType parses a section type given by name, with or without the
+// SHT_ prefix and in any case (e.g. "note", "SHT_NOBITS"), or as a number.
+func ParseSectionType(s string) (elf.SectionType, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	for _, t := range sectionTypes {
+		if t.String() == name || t.String() == "SHT_"+name {
+			return t, nil
+		}
+	}
+	n, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unknown section type %q", ErrInvalidArgument, s)
+	}
+	return elf.SectionType(n), nil
+}
+
+// ParseSectionFlags parses a comma-separated list of section flags given by
+// name, with or without the SHF_ prefix and in any case (e.g. "alloc,write"),
+// or as a number. "exec" is accepted for SHF_EXECINSTR and "none" or an empty
+// string for no flags.
+func ParseSectionFlags(s string) (elf.SectionFlag, error) {
+	var flags elf.SectionFlag
+	for _, part := range strings.Split(s, ",") {
+		name := strings.ToUpper(strings.TrimSpace(part))
+		switch name {
+		case "", "NONE":
+			continue
+		case "EXEC":
+			name = "EXECINSTR"
+		}
+		found := false
+		for _, fl := range sectionFlags {
+			if fl.String() == name || fl.String() == "SHF_"+name {
+				flags |= fl
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(part), 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: unknown section flag %q", ErrInvalidArgument, part)
+		}
+		flags |= elf.SectionFlag(n)
+	}
+	return flags, nil
+}
@@ -0,0 +1,151 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"testing"
+)
+
+func TestParseSectionType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want elf.SectionType
+		err  bool
+	}{
+		{"note", elf.SHT_NOTE, false},
+		{"NOBITS", elf.SHT_NOBITS, false},
+		{"SHT_PROGBITS", elf.SHT_PROGBITS, false},
+		{"sht_init_array", elf.SHT_INIT_ARRAY, false},
+		{" gnu_hash ", elf.SHT_GNU_HASH, false},
+		{"7", elf.SHT_NOTE, false},
+		{"0x6ffffff6", elf.SHT_GNU_HASH, false},
+		{"0x70000001", elf.SectionType(0x70000001), false},
+		{"", 0, true},
+		{"notes", 0, true},
+		{"SHT_", 0, true},
+		{"-1", 0, true},
+		{"0x100000000", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSectionType(tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("ParseSectionType(%q) = %v, %v, want %v", tt.in, got, err, ErrInvalidArgument)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSectionType(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseSectionFlags(t *testing.T) {
+	tests := []struct {
+		in   string
+		want elf.SectionFlag
+		err  bool
+	}{
+		{"", 0, false},
+		{"none", 0, false},
+		{"alloc", elf.SHF_ALLOC, false},
+		{"alloc,write", elf.SHF_ALLOC | elf.SHF_WRITE, false},
+		{"SHF_ALLOC, shf_execinstr", elf.SHF_ALLOC | elf.SHF_EXECINSTR, false},
+		{"exec", elf.SHF_EXECINSTR, false},
+		{"merge,strings", elf.SHF_MERGE | elf.SHF_STRINGS, false},
+		{"tls,0x10000000", elf.SHF_TLS | elf.SectionFlag(0x10000000), false},
+		{"6", elf.SHF_ALLOC | elf.SHF_EXECINSTR, false},
+		{"alloc,,write", elf.SHF_ALLOC | elf.SHF_WRITE, false},
+		{"allocate", 0, true},
+		{"alloc,bogus", 0, true},
+		{"SHF_", 0, true},
+		{"-2", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSectionFlags(tt.in)
+		if tt.err {
+			if !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("ParseSectionFlags(%q) = %v, %v, want %v", tt.in, got, err, ErrInvalidArgument)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseSectionFlags(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestSectionOptionsWritten(t *testing.T) {
+	_, f := readFile(t, "tiny64")
+	typ := elf.SectionType(0x70000001)
+	flags := elf.SHF_MERGE | elf.SHF_STRINGS | elf.SHF_INFO_LINK
+	addr, align, entsize := uint64(0x1234), uint64(16), uint64(1)
+	link, info := uint32(f.Section(".dynsym").Index()), uint32(f.Section(".text").Index())
+	opts := SectionOptions{Type: &typ, Flags: &flags, Addr: &addr, Addralign: &align, Entsize: &entsize, Link: &link, Info: &info}
+	if _, err := f.AddOrReplaceSectionWithOptions(".opts", []byte("a\x00b\x00"), opts); err != nil {
+		t.Fatal(err)
+	}
+	// UpdateSection only changes the selected fields
+	newAlign := uint64(32)
+	if err := f.UpdateSection(".comment", SectionOptions{Addralign: &newAlign}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ef, err := elf.NewFile(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := elf.SectionHeader{
+		Name: ".opts", Type: typ, Flags: flags, Addr: addr, Addralign: align, Entsize: entsize,
+		Link: link, Info: info, Size: 4, FileSize: 4,
+	}
+	got := ef.Section(".opts").SectionHeader
+	want.Offset = got.Offset
+	if got != want {
+		t.Errorf(".opts header = %+v, want %+v", got, want)
+	}
+	if got.Offset%align != 0 {
+		t.Errorf(".opts at 0x%x is not %d-byte aligned", got.Offset, align)
+	}
+	c := ef.Section(".comment")
+	if c.Addralign != newAlign || c.Offset%newAlign != 0 || c.Type != elf.SHT_PROGBITS || c.Flags != elf.SHF_MERGE|elf.SHF_STRINGS {
+		t.Errorf(".comment header = %+v", c.SectionHeader)
+	}
+}
+
+func TestSectionOptionsInvalid(t *testing.T) {
+	nobits := elf.SHT_NOBITS
+	align, link := uint64(12), uint32(1000)
+	tests := []struct {
+		name   string
+		object bool
+		opts   SectionOptions
+	}{
+		{"alignment", false, SectionOptions{Addralign: &align}},
+		{"link", false, SectionOptions{Link: &link}},
+		{"load nobits", false, SectionOptions{Type: &nobits, Load: true}},
+		{"load without segments", true, SectionOptions{Load: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := "tiny64"
+			if tt.object {
+				name = "hello64.o"
+			}
+			data, f := readFile(t, name)
+			if _, err := f.AddOrReplaceSectionWithOptions(".bad", []byte("x"), tt.opts); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("error = %v, want %v", err, ErrInvalidArgument)
+			}
+			if err := f.UpdateSection(".comment", tt.opts); !errors.Is(err, ErrInvalidArgument) {
+				t.Errorf("update: error = %v, want %v", err, ErrInvalidArgument)
+			}
+			if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+				t.Errorf("invalid options changed the file: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package elfy
+
+import (
+	"debug/elf"
+)
+
+// The overlay is any data appended after the end of the ELF image, such as the
+// SquashFS payload of an AppImage, a self-extracting archive, or a kernel module
+// signature. The end of the ELF image is the furthest byte referenced by the ELF
+// header, the program and section header tables, any segment, or any section.
+// Edits keep the overlay by default and write it directly after the new end of
+// the ELF image.
+
+// HasOverlay reports whether the file carries data after the end of its ELF image.
+func (f *File) HasOverlay() bool {
+	return len(f.overlay) > 0
+}
+
+// OverlayOffset returns the offset at which the overlay starts in the parsed ELF data.
+// When there is no overlay this is the size of the ELF image.
+func (f *File) OverlayOffset() uint64 {
+	return f.overlayOff
+}
+
+// Overlay returns a copy of the data appended after the end of the ELF image.
+//
+// Returns:
+//   - A byte slice containing the overlay, or nil if the file has none.
+func (f *File) Overlay() []byte {
+	if len(f.overlay) == 0 {
+		return nil
+	}
+	return append([]byte(nil), f.overlay...)
+}
+
+// SetOverlay replaces the data written after the end of the ELF image.
+// Passing nil or an empty slice removes the overlay.
+//
+// Parameters:
+//   - data: The raw bytes to append after the ELF image.
+func (f *File) SetOverlay(data []byte) {
+	f.overlay = append([]byte(nil), data...)
+}
+
+// StripOverlay removes any data appended after the end of the ELF image.
+func (f *File) StripOverlay() {
+	f.overlay = nil
+}
+
+// imageEnd returns the offset just past the last byte referenced by the ELF
+// structures of the parsed file, clamped to the size of the data.
+func (f *File) imageEnd() uint64 {
+	end := uint64(f.ehsize)
+	if f.phnum > 0 {
+		end = max(end, f.phoff+uint64(f.phnum)*uint64(f.phentsize))
+	}
+	if len(f.Sections) > 0 {
+		end = max(end, f.shoff+uint64(len(f.Sections))*uint64(f.shentsize))
+	}
+	for _, p := range f.Segments {
+		end = max(end, p.Off+p.Filesz)
+	}
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOBITS {
+			end = max(end, s.Offset+s.Size)
+		}
+	}
+	return min(end, uint64(len(f.raw)))
+}
+
+// ReadOverlay returns the data appended after the end of the ELF image.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//
+// Returns:
+//   - A byte slice containing the overlay, or nil if there is none.
+//   - An error if the ELF data is invalid or cannot be parsed.
+func ReadOverlay(elfData []byte) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	return f.Overlay(), nil
+}
+
+// ReplaceOverlay replaces the data appended after the end of the ELF image.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//   - overlay: The raw bytes to append after the ELF image; nil removes the overlay.
+//
+// Returns:
+//   - A byte slice containing the modified ELF file data.
+//   - An error if the ELF data is invalid or the operation fails.
+func ReplaceOverlay(elfData []byte, overlay []byte) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	f.SetOverlay(overlay)
+	return f.Bytes()
+}
+
+// StripOverlay removes the data appended after the end of the ELF image.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//
+// Returns:
+//   - A byte slice containing the ELF image without its overlay.
+//   - An error if the ELF data is invalid or the file has no overlay.
+func StripOverlay(elfData []byte) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	if !f.HasOverlay() {
+		return nil, ErrNoOverlay
+	}
+	f.StripOverlay()
+	return f.Bytes()
+}
@@ -0,0 +1,101 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	image, f := readFile(t, "tiny64")
+	if f.HasOverlay() || f.Overlay() != nil || f.OverlayOffset() != uint64(len(image)) {
+		t.Fatalf("tiny64 has an overlay at 0x%x", f.OverlayOffset())
+	}
+	if _, err := StripOverlay(image); !errors.Is(err, ErrNoOverlay) {
+		t.Errorf("stripping a missing overlay: error = %v, want %v", err, ErrNoOverlay)
+	}
+
+	payload := []byte("hsqs appended payload")
+	data := append(bytes.Clone(image), payload...)
+	f = parseFile(t, data)
+	if !f.HasOverlay() || f.OverlayOffset() != uint64(len(image)) || !bytes.Equal(f.Overlay(), payload) {
+		t.Errorf("overlay = %q at 0x%x, want %q at 0x%x", f.Overlay(), f.OverlayOffset(), payload, len(image))
+	}
+	if got, err := ReadOverlay(data); err != nil || !bytes.Equal(got, payload) {
+		t.Errorf("ReadOverlay = %q, %v", got, err)
+	}
+	if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("file with overlay does not round-trip: %v", err)
+	}
+
+	replaced, err := ReplaceOverlay(data, []byte("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(bytes.Clone(image), "other"...); !bytes.Equal(replaced, want) {
+		t.Errorf("replaced overlay: output ends with %q", replaced[len(image):])
+	}
+	stripped, err := StripOverlay(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stripped, image) {
+		t.Errorf("stripped output has %d bytes, want the %d-byte image", len(stripped), len(image))
+	}
+	if removed, err := ReplaceOverlay(data, nil); err != nil || !bytes.Equal(removed, image) {
+		t.Errorf("replacing the overlay with nil did not strip it: %v", err)
+	}
+}
+
+func TestOverlayKeptByEdits(t *testing.T) {
+	image, _ := readFile(t, "tiny64")
+	payload := bytes.Repeat([]byte("overlay!"), 100)
+	data := append(bytes.Clone(image), payload...)
+	noFlags := elf.SectionFlag(0)
+	edits := map[string]func(f *File) error{
+		"add": func(f *File) error {
+			_, err := f.AddOrReplaceSectionWithOptions(".added", bytes.Repeat([]byte{0xcc}, 300), SectionOptions{Flags: &noFlags})
+			return err
+		},
+		"load": func(f *File) error {
+			_, err := f.AddOrReplaceSectionWithOptions(".loaded", []byte("mapped"), SectionOptions{Load: true})
+			return err
+		},
+		"remove": func(f *File) error { return f.RemoveSection(".comment") },
+	}
+	for _, layout := range []LayoutStrategy{LayoutReuse, LayoutAppend, LayoutCompact} {
+		for name, edit := range edits {
+			t.Run(layout.String()+"/"+name, func(t *testing.T) {
+				f := parseFile(t, data)
+				f.Layout = layout
+				if err := edit(f); err != nil {
+					t.Fatal(err)
+				}
+				out, err := f.Bytes()
+				if err != nil {
+					t.Fatal(err)
+				}
+				g := parseFile(t, out)
+				checkVerify(t, g)
+				if !bytes.Equal(g.Overlay(), payload) || !bytes.Equal(out[g.OverlayOffset():], payload) {
+					t.Fatalf("overlay not kept after the edited image at 0x%x", g.OverlayOffset())
+				}
+
+				// The image is written as if there were no overlay
+				f = parseFile(t, image)
+				f.Layout = layout
+				if err := edit(f); err != nil {
+					t.Fatal(err)
+				}
+				want, err := f.Bytes()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(out[:g.OverlayOffset()], want) {
+					t.Errorf("image before the overlay differs from the edit without overlay")
+				}
+			})
+		}
+	}
+}
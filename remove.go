@@ -0,0 +1,309 @@
+package elfy
+
+import (
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+// RemoveSection removes the section with the given name from the section header table.
+// Every section index stored in the file (e_shstrndx, sh_link and sh_info of
+// other sections, st_shndx of symbols and SHT_GROUP member lists) is renumbered
+// to match the new table. The removal is refused if another section, a symbol
+// or a section group still refers to the section; see RemoveSectionCascade.
+//
+// Parameters:
+//   - name: The name of the section to remove.
+//
+// Returns:
+//   - An error if the section is not found, is still referenced, or cannot be removed.
+func (f *File) RemoveSection(name string) error {
+	_, err := f.removeSection(name, false)
+	return err
+}
+
+// RemoveSectionCascade removes the section with the given name together with
+// every section that depends on it through sh_link or sh_info, such as the
+// relocation sections that apply to it. Symbols defined in a removed section
+// become undefined (SHN_UNDEF) and removed sections are dropped from groups.
+//
+// Parameters:
+//   - name: The name of the section to remove.
+//
+// Returns:
+//   - The names of all removed sections, starting with the requested one.
+//   - An error if the section is not found or cannot be removed.
+func (f *File) RemoveSectionCascade(name string) ([]string, error) {
+	return f.removeSection(name, true)
+}
+
+func (f *File) removeSection(name string, cascade bool) ([]string, error) {
+	target := f.Section(name)
+	if target == nil {
+		return nil, fmt.Errorf("%w: %s", ErrSectionNotFound, name)
+	}
+	idx := target.Index()
+	if idx == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the null section", ErrInvalidArgument)
+	}
+
+	// Without cascading nothing may refer to the section
+	if !cascade {
+		for j, s := range f.Sections {
+			if field := s.refField(idx); field != "" && j != idx {
+				return nil, fmt.Errorf("%w: %s by %s (%s)", ErrSectionReferenced, target.Name, s.Name, field)
+			}
+		}
+	}
+	order := f.dependents(idx)
+	removed := make(map[int]bool, len(order))
+	for _, i := range order {
+		removed[i] = true
+	}
+	var names []string
+	removedSections := make(map[*Section]bool)
+	for _, i := range order {
+		removedSections[f.Sections[i]] = true
+		if f.Sections[i] == f.shstrtab {
+			return nil, fmt.Errorf("%w: cannot remove section header string table %s", ErrInvalidArgument, f.Sections[i].Name)
+		}
+		names = append(names, f.Sections[i].Name)
+	}
+
+	newIndex := make([]int, len(f.Sections))
+	next := 0
+	for i := range f.Sections {
+		if removed[i] {
+			newIndex[i] = -1
+			continue
+		}
+		newIndex[i] = next
+		next++
+	}
+
+	// Compute every content fix-up before touching the file so a refusal leaves it unchanged
+	patches := make(map[*Section][]byte)
+	for i, s := range f.Sections {
+		if removed[i] {
+			continue
+		}
+		switch s.Type {
+		case elf.SHT_SYMTAB, elf.SHT_DYNSYM:
+			if err := f.renumberSymbols(i, newIndex, cascade, patches); err != nil {
+				return nil, err
+			}
+		case elf.SHT_GROUP:
+			data, err := f.renumberGroup(s, newIndex, cascade)
+			if err != nil {
+				return nil, err
+			}
+			if data != nil {
+				patches[s] = data
+			}
+		}
+	}
+
+	for s, data := range patches {
+		if err := s.rewrite(data); err != nil {
+			return nil, err
+		}
+	}
+	for i, s := range f.Sections {
+		if removed[i] {
+			continue
+		}
+		if s.Link != 0 && int(s.Link) < len(newIndex) {
+			s.Link = uint32(max(newIndex[s.Link], 0))
+		}
+		if s.infoIsSection() && s.Info != 0 && int(s.Info) < len(newIndex) {
+			s.Info = uint32(max(newIndex[s.Info], 0))
+		}
+	}
+	f.Sections = slices.DeleteFunc(f.Sections, func(s *Section) bool {
+		return removedSections[s]
+	})
+	return names, nil
+}
+
+// dependents returns idx followed by the index of every section that refers
+// to it through sh_link or sh_info, directly or through another dependent.
+func (f *File) dependents(idx int) []int {
+	seen := map[int]bool{idx: true}
+	order := []int{idx}
+	for q := 0; q < len(order); q++ {
+		for j, s := range f.Sections {
+			if !seen[j] && s.refField(order[q]) != "" {
+				seen[j] = true
+				order = append(order, j)
+			}
+		}
+	}
+	return order
+}
+
+// refField reports which header field of s refers to the section at index i,
+// or "" if none does.
+func (s *Section) refField(i int) string {
+	if s.Link != 0 && int(s.Link) == i {
+		return "sh_link"
+	}
+	if s.infoIsSection() && s.Info != 0 && int(s.Info) == i {
+		return "sh_info"
+	}
+	return ""
+}
+
+// infoIsSection reports whether the section's sh_info holds a section index.
+func (s *Section) infoIsSection() bool {
+	return s.Flags&elf.SHF_INFO_LINK != 0 || s.Type == elf.SHT_REL || s.Type == elf.SHT_RELA
+}
+
+// renumberSymbols rewrites st_shndx of every symbol in the symbol table at
+// index i using newIndex, including indices stored in an associated
+// SHT_SYMTAB_SHNDX table. Changed contents are recorded in patches.
+func (f *File) renumberSymbols(i int, newIndex []int, cascade bool, patches map[*Section][]byte) error {
+	s := f.Sections[i]
+	data, err := s.Data()
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", s.Name, err)
+	}
+	var xdata []byte
+	xs := f.symtabShndx(i)
+	if xs != nil {
+		if xdata, err = xs.Data(); err != nil {
+			return fmt.Errorf("error reading %s: %w", xs.Name, err)
+		}
+	}
+
+	entSize, shndxOff := f.symbolSize(), f.symbolShndxOffset()
+	changed, xchanged := false, false
+	for off := 0; off+entSize <= len(data); off += entSize {
+		shndx := uint32(f.ByteOrder.Uint16(data[off+shndxOff:]))
+		xoff := off / entSize * 4
+		extended := shndx == uint32(elf.SHN_XINDEX) && xoff+4 <= len(xdata)
+		if extended {
+			shndx = f.ByteOrder.Uint32(xdata[xoff:])
+		} else if shndx >= uint32(elf.SHN_LORESERVE) {
+			continue
+		}
+		if shndx == uint32(elf.SHN_UNDEF) || int(shndx) >= len(newIndex) {
+			continue
+		}
+		n := newIndex[shndx]
+		if n == -1 {
+			if !cascade {
+				sym := f.symbolName(s, data[off:off+entSize])
+				if sym == "" {
+					sym = fmt.Sprintf("#%d", off/entSize)
+				}
+				return fmt.Errorf("%w: %s by symbol %s in %s", ErrSectionReferenced, f.Sections[shndx].Name, sym, s.Name)
+			}
+			n = int(elf.SHN_UNDEF)
+			if extended {
+				f.ByteOrder.PutUint16(data[off+shndxOff:], uint16(elf.SHN_UNDEF))
+				changed = true
+			}
+		}
+		if n == int(shndx) {
+			continue
+		}
+		if extended {
+			f.ByteOrder.PutUint32(xdata[xoff:], uint32(n))
+			xchanged = true
+		} else {
+			f.ByteOrder.PutUint16(data[off+shndxOff:], uint16(n))
+			changed = true
+		}
+	}
+	if changed {
+		patches[s] = data
+	}
+	if xchanged {
+		patches[xs] = xdata
+	}
+	return nil
+}
+
+// symtabShndx returns the SHT_SYMTAB_SHNDX section that extends the symbol
+// table at index i, or nil if it has none.
+func (f *File) symtabShndx(i int) *Section {
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_SYMTAB_SHNDX && int(s.Link) == i {
+			return s
+		}
+	}
+	return nil
+}
+
+// renumberGroup rewrites the member list of the SHT_GROUP section s using
+// newIndex, dropping removed members when cascading and refusing the removal
+// otherwise. It returns nil if nothing changes.
+func (f *File) renumberGroup(s *Section, newIndex []int, cascade bool) ([]byte, error) {
+	data, err := s.Data()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", s.Name, err)
+	}
+	if len(data) < 4 {
+		return nil, nil
+	}
+	out := append([]byte(nil), data[:4]...)
+	for off := 4; off+4 <= len(data); off += 4 {
+		member := f.ByteOrder.Uint32(data[off:])
+		if int(member) < len(newIndex) {
+			if newIndex[member] == -1 {
+				if !cascade {
+					return nil, fmt.Errorf("%w: %s by group %s (member)", ErrSectionReferenced, f.Sections[member].Name, s.Name)
+				}
+				continue
+			}
+			member = uint32(newIndex[member])
+		}
+		var word [4]byte
+		f.ByteOrder.PutUint32(word[:], member)
+		out = append(out, word[:]...)
+	}
+	if slices.Equal(out, data) {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// symbolName resolves the name of a raw symbol table entry through the table's linked string table.
+func (f *File) symbolName(symtab *Section, entry []byte) string {
+	if int(symtab.Link) >= len(f.Sections) {
+		return ""
+	}
+	strtab, err := f.Sections[symtab.Link].Data()
+	if err != nil {
+		return ""
+	}
+	return getString(strtab, int(f.ByteOrder.Uint32(entry)))
+}
+
+// rewrite replaces the section's contents after a fix-up that does not grow it.
+// Sections that still live at their original offset are overwritten in place,
+// so allocated tables such as .dynsym stay where the loader expects them.
+func (s *Section) rewrite(data []byte) error {
+	if s.dirty || s.added {
+		s.data = data
+		s.Size = uint64(len(data))
+		return nil
+	}
+	return s.SetDataInPlace(data, false)
+}
+
+// symbolSize returns the size of one symbol table entry for the file's class.
+func (f *File) symbolSize() int {
+	if f.Class == elf.ELFCLASS64 {
+		return 24
+	}
+	return 16
+}
+
+// symbolShndxOffset returns the offset of st_shndx within a symbol table entry.
+func (f *File) symbolShndxOffset() int {
+	if f.Class == elf.ELFCLASS64 {
+		return 6
+	}
+	return 14
+}
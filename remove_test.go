@@ -0,0 +1,156 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// groupBuilder returns a relocatable object of the given target whose COMDAT
+// group [2] holds .text.a [3] and its relocations [4], after a .data section [1].
+func groupBuilder(target string) *Builder {
+	b := testBuilders()[target+"/rel"]
+	order := binary.AppendByteOrder(binary.LittleEndian)
+	if b.Data == elf.ELFDATA2MSB {
+		order = binary.BigEndian
+	}
+	group := order.AppendUint32(nil, 1) // GRP_COMDAT
+	group = order.AppendUint32(group, 3)
+	group = order.AppendUint32(group, 4)
+	relaSize := uint64(24)
+	if b.Class == elf.ELFCLASS32 {
+		relaSize = 12
+	}
+	b.Sections = append([]BuildSection{
+		{Name: ".data", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_WRITE, Addralign: 4, Data: []byte("data")},
+		{Name: ".group", Type: elf.SHT_GROUP, Addralign: 4, Entsize: 4, Link: ".symtab", Info: 4, Data: group},
+		{Name: ".text.a", Type: elf.SHT_PROGBITS, Flags: elf.SHF_ALLOC | elf.SHF_EXECINSTR | elf.SHF_GROUP, Addralign: 4, Data: []byte{0, 0, 0, 0}},
+		{Name: ".rela.text.a", Type: elf.SHT_RELA, Flags: elf.SHF_INFO_LINK | elf.SHF_GROUP, Addralign: 8, Entsize: relaSize, Link: ".symtab", InfoLink: ".text.a"},
+	}, b.Sections...)
+	// blob and blob_size come first, so the group signature a is symbol 4
+	b.Symbols = append(b.Symbols,
+		BuildSymbol{Name: "d", Section: ".data", Bind: elf.STB_GLOBAL, Type: elf.STT_OBJECT, Size: 4},
+		BuildSymbol{Name: "a", Section: ".text.a", Bind: elf.STB_GLOBAL, Type: elf.STT_FUNC, Size: 4},
+	)
+	return b
+}
+
+// groupMembers returns the member indices of the SHT_GROUP section s.
+func groupMembers(t *testing.T, ef *elf.File, s *elf.Section) []uint32 {
+	t.Helper()
+	data, err := s.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var members []uint32
+	for off := 4; off+4 <= len(data); off += 4 {
+		members = append(members, ef.ByteOrder.Uint32(data[off:]))
+	}
+	return members
+}
+
+func TestRemoveSectionRenumbers(t *testing.T) {
+	for _, target := range []string{"x86_64", "i386", "ppc64", "mips"} {
+		t.Run(target, func(t *testing.T) {
+			data, f := buildFile(t, groupBuilder(target))
+			if err := f.RemoveSection(".data"); !errors.Is(err, ErrSectionReferenced) {
+				t.Errorf("removing .data defining d: error = %v, want %v", err, ErrSectionReferenced)
+			}
+			removed, err := f.RemoveSectionCascade(".data")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(removed, []string{".data"}) {
+				t.Errorf("removed %q", removed)
+			}
+			out, err := f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkVerify(t, parseFile(t, out))
+
+			ef, err := elf.NewFile(bytes.NewReader(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, s := range ef.Sections[1:] {
+				names = append(names, s.Name)
+			}
+			want := []string{".group", ".text.a", ".rela.text.a", ".rodata", ".symtab", ".strtab", ".shstrtab"}
+			if !slices.Equal(names, want) {
+				t.Fatalf("sections = %q, want %q", names, want)
+			}
+			group, rela, symtab := ef.Section(".group"), ef.Section(".rela.text.a"), ef.Section(".symtab")
+			if group.Link != 5 || group.Info != 4 {
+				t.Errorf(".group sh_link = %d, sh_info = %d, want 5, 4", group.Link, group.Info)
+			}
+			if m := groupMembers(t, ef, group); !slices.Equal(m, []uint32{2, 3}) {
+				t.Errorf(".group members = %v, want [2 3]", m)
+			}
+			if rela.Link != 5 || rela.Info != 2 {
+				t.Errorf(".rela.text.a sh_link = %d, sh_info = %d, want 5, 2", rela.Link, rela.Info)
+			}
+			if symtab.Link != 6 {
+				t.Errorf(".symtab sh_link = %d, want 6", symtab.Link)
+			}
+			syms, err := ef.Symbols()
+			if err != nil {
+				t.Fatal(err)
+			}
+			shndx := make(map[string]elf.SectionIndex)
+			for _, sym := range syms {
+				shndx[sym.Name] = sym.Section
+			}
+			if shndx["a"] != 2 || shndx["blob"] != 4 || shndx["d"] != elf.SHN_UNDEF || shndx["blob_size"] != elf.SHN_ABS {
+				t.Errorf("st_shndx = %v", shndx)
+			}
+
+			// Group members are only dropped from the group when cascading
+			f = parseFile(t, data)
+			err = f.RemoveSection(".rela.text.a")
+			if !errors.Is(err, ErrSectionReferenced) || !strings.Contains(err.Error(), "group .group") {
+				t.Errorf("removing group member .rela.text.a: error = %v, want %v naming .group", err, ErrSectionReferenced)
+			}
+			if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+				t.Errorf("refused removal changed the file: %v", err)
+			}
+			if _, err := f.RemoveSectionCascade(".rela.text.a"); err != nil {
+				t.Fatal(err)
+			}
+			if out, err = f.Bytes(); err != nil {
+				t.Fatal(err)
+			}
+			if ef, err = elf.NewFile(bytes.NewReader(out)); err != nil {
+				t.Fatal(err)
+			}
+			if m := groupMembers(t, ef, ef.Section(".group")); !slices.Equal(m, []uint32{3}) {
+				t.Errorf(".group members = %v, want [3]", m)
+			}
+
+			// Cascading from a group member drops its relocations and both group entries
+			f = parseFile(t, data)
+			removed, err = f.RemoveSectionCascade(".text.a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(removed, []string{".text.a", ".rela.text.a"}) {
+				t.Errorf("removed %q", removed)
+			}
+			out, err = f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ef, err = elf.NewFile(bytes.NewReader(out)); err != nil {
+				t.Fatal(err)
+			}
+			if m := groupMembers(t, ef, ef.Section(".group")); len(m) != 0 {
+				t.Errorf(".group members = %v, want none", m)
+			}
+		})
+	}
+}
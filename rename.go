@@ -0,0 +1,68 @@
+package elfy
+
+import (
+	"fmt"
+)
+
+// RenameSection changes the name of a section, keeping its contents and every
+// other header field. The new name is stored in .shstrtab when the file is
+// written, reusing an existing string or the tail of a longer one when possible.
+//
+// Parameters:
+//   - oldName: The current name of the section.
+//   - newName: The new name of the section.
+//
+// Returns:
+//   - An error if the section is not found or a section named newName already exists.
+func (f *File) RenameSection(oldName, newName string) error {
+	return f.RenameSectionWithOptions(oldName, newName, SectionOptions{})
+}
+
+// RenameSectionWithOptions renames a section like RenameSection and applies the
+// header attributes selected by opts, like objcopy's --rename-section old=new,flags.
+//
+// Parameters:
+//   - oldName: The current name of the section.
+//   - newName: The new name of the section.
+//   - opts: The header attributes to set.
+//
+// Returns:
+//   - An error if the section is not found, the new name is taken or the options are invalid.
+func (f *File) RenameSectionWithOptions(oldName, newName string, opts SectionOptions) error {
+	s := f.Section(oldName)
+	if s == nil {
+		return fmt.Errorf("%w: %s", ErrSectionNotFound, oldName)
+	}
+	if newName == "" {
+		return fmt.Errorf("%w: section name cannot be empty", ErrInvalidArgument)
+	}
+	if newName != oldName && f.Section(newName) != nil {
+		return fmt.Errorf("%w: %s", ErrSectionExists, newName)
+	}
+	if err := opts.validate(f); err != nil {
+		return err
+	}
+	s.Name = newName
+	return s.Apply(opts)
+}
+
+// RenameSection renames a section of the ELF data; see File.RenameSection.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//   - oldName: The current name of the section.
+//   - newName: The new name of the section.
+//
+// Returns:
+//   - A byte slice containing the modified ELF file data.
+//   - An error if the ELF data is invalid or the section cannot be renamed.
+func RenameSection(elfData []byte, oldName, newName string) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.RenameSection(oldName, newName); err != nil {
+		return nil, err
+	}
+	return f.Bytes()
+}
@@ -0,0 +1,113 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"testing"
+)
+
+func TestRenameSectionSharesSuffix(t *testing.T) {
+	data, f := readFile(t, "tiny64")
+	strtab, err := f.shstrtab.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rela := f.Section(".rela.dyn")
+	i := f.Section(".comment").Index()
+	if err := f.RenameSection(".comment", ".dyn"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// .dyn is the tail of .rela.dyn, so only sh_name of the section changes
+	g := parseFile(t, out)
+	if got, want := g.Sections[i].nameOff, rela.nameOff+5; got != want {
+		t.Errorf("sh_name = 0x%x, want 0x%x inside .rela.dyn", got, want)
+	}
+	if got, err := g.shstrtab.Data(); err != nil || !bytes.Equal(got, strtab) {
+		t.Errorf(".shstrtab changed: %v", err)
+	}
+	nameField := int(f.shoff) + i*f.sectionHeaderSize()
+	if len(out) != len(data) {
+		t.Fatalf("size changed from %d to %d", len(data), len(out))
+	}
+	for off := range data {
+		if data[off] != out[off] && (off < nameField || off >= nameField+4) {
+			t.Fatalf("byte 0x%x changed outside sh_name of the renamed section", off)
+		}
+	}
+}
+
+func TestRenameSectionKeepsHeader(t *testing.T) {
+	data, f := readFile(t, "tiny64")
+	orig, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// .ro is only a prefix of .rodata, so .shstrtab grows and the layout changes
+	if err := f.RenameSection(".rodata", ".ro"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkVerify(t, parseFile(t, out))
+	ef, err := elf.NewFile(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ef.Section(".shstrtab").Size, orig.Section(".shstrtab").Size+4; got != want {
+		t.Errorf(".shstrtab size = %d, want %d", got, want)
+	}
+	for i, s := range orig.Sections {
+		want := s.SectionHeader
+		if want.Name == ".rodata" {
+			want.Name = ".ro"
+		}
+		got := ef.Sections[i].SectionHeader
+		// Only unallocated sections after the grown .shstrtab may move
+		if want.Flags&elf.SHF_ALLOC == 0 {
+			want.Offset = got.Offset
+		}
+		if want.Name == ".shstrtab" {
+			want.Size, want.FileSize = got.Size, got.FileSize
+		}
+		if got != want {
+			t.Errorf("section %d = %+v, want %+v", i, got, want)
+		}
+		a, _ := s.Data()
+		b, _ := ef.Sections[i].Data()
+		if want.Name != ".shstrtab" && !bytes.Equal(a, b) {
+			t.Errorf("contents of section %d changed", i)
+		}
+	}
+}
+
+func TestRenameSectionErrors(t *testing.T) {
+	data, f := readFile(t, "tiny64")
+	align := uint64(3)
+	tests := []struct {
+		name     string
+		from, to string
+		opts     SectionOptions
+		check    error
+	}{
+		{"unknown", ".nope", ".x", SectionOptions{}, ErrSectionNotFound},
+		{"taken", ".comment", ".text", SectionOptions{}, ErrSectionExists},
+		{"empty", ".comment", "", SectionOptions{}, ErrInvalidArgument},
+		{"options", ".comment", ".x", SectionOptions{Addralign: &align}, ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		if err := f.RenameSectionWithOptions(tt.from, tt.to, tt.opts); !errors.Is(err, tt.check) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.check)
+		}
+	}
+	if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("refused renames changed the file: %v", err)
+	}
+}
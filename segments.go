@@ -0,0 +1,279 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+)
+
+// Segment is a single program header of a File.
+// Header fields can be inspected and modified directly; UpdateSegment does the
+// same with validation.
+type Segment struct {
+	elf.ProgHeader
+}
+
+// readSegments decodes the program header table.
+func (f *File) readSegments() error {
+	if f.phnum == 0 {
+		return nil
+	}
+	if err := checkLimit("MaxSegments", uint64(f.phnum), uint64(f.Limits.MaxSegments)); err != nil {
+		return err
+	}
+	if int(f.phentsize) != f.progHeaderSize() {
+		return formatErrorf(0, "e_phentsize", "invalid program header entry size %d", f.phentsize)
+	}
+	if !inBounds(f.phoff, uint64(f.phnum)*uint64(f.phentsize), uint64(len(f.raw))) {
+		return formatErrorf(f.phoff, "e_phoff", "program header table out of bounds")
+	}
+	r := bytes.NewReader(f.raw[f.phoff:])
+	f.Segments = make([]*Segment, f.phnum)
+	for i := range f.Segments {
+		if f.Class == elf.ELFCLASS64 {
+			var ph elf.Prog64
+			if err := binary.Read(r, f.ByteOrder, &ph); err != nil {
+				return f.progHeaderError(i, err)
+			}
+			f.Segments[i] = &Segment{elf.ProgHeader{
+				Type: elf.ProgType(ph.Type), Flags: elf.ProgFlag(ph.Flags),
+				Off: ph.Off, Vaddr: ph.Vaddr, Paddr: ph.Paddr,
+				Filesz: ph.Filesz, Memsz: ph.Memsz, Align: ph.Align,
+			}}
+			continue
+		}
+		var ph elf.Prog32
+		if err := binary.Read(r, f.ByteOrder, &ph); err != nil {
+			return f.progHeaderError(i, err)
+		}
+		f.Segments[i] = &Segment{elf.ProgHeader{
+			Type: elf.ProgType(ph.Type), Flags: elf.ProgFlag(ph.Flags),
+			Off: uint64(ph.Off), Vaddr: uint64(ph.Vaddr), Paddr: uint64(ph.Paddr),
+			Filesz: uint64(ph.Filesz), Memsz: uint64(ph.Memsz), Align: uint64(ph.Align),
+		}}
+	}
+	return nil
+}
+
+// progHeaderError reports a program header i that could not be decoded.
+func (f *File) progHeaderError(i int, err error) error {
+	off := f.phoff + uint64(i)*uint64(f.phentsize)
+	return &FormatError{Offset: off, Field: "program header", Msg: "truncated program header", Err: err}
+}
+
+// AddSegment appends a program header. PT_LOAD segments are inserted after the
+// last existing PT_LOAD to keep them sorted. The program header table grows or
+// moves when the file is written; see File.PlanLayout.
+//
+// Parameters:
+//   - p: The program header to add.
+//
+// Returns:
+//   - A pointer to the new Segment.
+//   - An error if the program header is invalid or a PT_LOAD overlaps another one.
+func (f *File) AddSegment(p elf.ProgHeader) (*Segment, error) {
+	if err := f.checkSegment(p); err != nil {
+		return nil, err
+	}
+	if err := f.checkOverlap(p, -1); err != nil {
+		return nil, err
+	}
+	seg := &Segment{p}
+	at := len(f.Segments)
+	if p.Type == elf.PT_LOAD {
+		at = 0
+		for i, s := range f.Segments {
+			if s.Type == elf.PT_LOAD {
+				at = i + 1
+			}
+		}
+	}
+	f.Segments = append(f.Segments[:at], append([]*Segment{seg}, f.Segments[at:]...)...)
+	return seg, nil
+}
+
+// RemoveSegment removes the program header at index i.
+//
+// Parameters:
+//   - i: The index of the program header to remove.
+//
+// Returns:
+//   - An error if i is out of range.
+func (f *File) RemoveSegment(i int) error {
+	if i < 0 || i >= len(f.Segments) {
+		return fmt.Errorf("%w: %d", ErrSegmentNotFound, i)
+	}
+	f.Segments = append(f.Segments[:i:i], f.Segments[i+1:]...)
+	return nil
+}
+
+// UpdateSegment replaces the program header at index i. The change is refused
+// if a section that lies inside the segment would end up partially outside it.
+//
+// Parameters:
+//   - i: The index of the program header to update.
+//   - p: The new program header.
+//
+// Returns:
+//   - An error if i is out of range, the new program header is invalid or a
+//     PT_LOAD overlaps another one.
+func (f *File) UpdateSegment(i int, p elf.ProgHeader) error {
+	if i < 0 || i >= len(f.Segments) {
+		return fmt.Errorf("%w: %d", ErrSegmentNotFound, i)
+	}
+	if err := f.checkSegment(p); err != nil {
+		return err
+	}
+	if err := f.checkOverlap(p, i); err != nil {
+		return err
+	}
+	seg := f.Segments[i]
+	updated := &Segment{p}
+	for _, s := range f.SegmentSections(seg) {
+		if !f.sectionInSegment(s, updated) {
+			return fmt.Errorf("%w: section %s would no longer fit in segment %d", ErrInvalidArgument, s.Name, i)
+		}
+	}
+	seg.ProgHeader = p
+	return nil
+}
+
+// checkSegment validates a single program header against the file.
+func (f *File) checkSegment(p elf.ProgHeader) error {
+	if p.Filesz > p.Memsz && p.Type == elf.PT_LOAD {
+		return fmt.Errorf("%w: segment file size %d exceeds memory size %d", ErrInvalidArgument, p.Filesz, p.Memsz)
+	}
+	if p.Align > 1 {
+		if p.Align&(p.Align-1) != 0 {
+			return fmt.Errorf("%w: segment alignment %d is not a power of two", ErrInvalidArgument, p.Align)
+		}
+		if p.Type == elf.PT_LOAD && p.Vaddr%p.Align != p.Off%p.Align {
+			return fmt.Errorf("%w: segment address 0x%x and offset 0x%x are not congruent modulo alignment 0x%x", ErrInvalidArgument, p.Vaddr, p.Off, p.Align)
+		}
+	}
+	return nil
+}
+
+// checkOverlap refuses a PT_LOAD segment whose memory range overlaps that of
+// another PT_LOAD segment of the file, other than the one at index skip.
+func (f *File) checkOverlap(p elf.ProgHeader, skip int) error {
+	if p.Type != elf.PT_LOAD || p.Memsz == 0 {
+		return nil
+	}
+	for i, q := range f.Segments {
+		if i != skip && q.Type == elf.PT_LOAD && q.Memsz > 0 && p.Vaddr < q.Vaddr+q.Memsz && q.Vaddr < p.Vaddr+p.Memsz {
+			return fmt.Errorf("%w: segment at 0x%x overlaps PT_LOAD segment %d at 0x%x", ErrInvalidArgument, p.Vaddr, i, q.Vaddr)
+		}
+	}
+	return nil
+}
+
+// SegmentSections returns the sections that lie inside the given segment, in
+// section table order, following the same rules as readelf's section to
+// segment mapping.
+//
+// Parameters:
+//   - seg: The segment to inspect.
+//
+// Returns:
+//   - The sections covered by the segment.
+func (f *File) SegmentSections(seg *Segment) []*Section {
+	var sections []*Section
+	for _, s := range f.Sections {
+		if f.sectionInSegment(s, seg) {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// sectionInSegment reports whether section s lies inside segment p: sections
+// with contents must be within its file range and allocated sections within its
+// memory range.
+func (f *File) sectionInSegment(s *Section, p *Segment) bool {
+	if s.Type == elf.SHT_NULL || p.Type == elf.PT_PHDR {
+		return false
+	}
+	tls := s.Flags&elf.SHF_TLS != 0
+	if p.Type == elf.PT_TLS && !tls {
+		return false
+	}
+	if tls && p.Type != elf.PT_TLS && p.Type != elf.PT_LOAD && p.Type != elf.PT_GNU_RELRO {
+		return false
+	}
+	// .tbss occupies no memory outside the TLS template
+	if tls && s.Type == elf.SHT_NOBITS && p.Type != elf.PT_TLS {
+		return false
+	}
+	if s.Type != elf.SHT_NOBITS {
+		if s.Offset < p.Off || s.Offset+s.Size > p.Off+p.Filesz || (s.Size == 0 && s.Offset >= p.Off+p.Filesz && p.Filesz > 0) {
+			return false
+		}
+		if p.Filesz == 0 {
+			return false
+		}
+	}
+	if s.Flags&elf.SHF_ALLOC != 0 {
+		if s.Addr < p.Vaddr || s.Addr+s.Size > p.Vaddr+p.Memsz || (s.Size == 0 && s.Addr >= p.Vaddr+p.Memsz) {
+			return false
+		}
+	} else if p.Type == elf.PT_LOAD || s.Type == elf.SHT_NOBITS {
+		return false
+	}
+	return true
+}
+
+// CheckSegments verifies that every allocated section with contents lies
+// entirely inside a PT_LOAD segment and that no section straddles the file
+// range of any segment.
+//
+// Returns:
+//   - An error describing the first violation found, or nil.
+func (f *File) CheckSegments() error {
+	hasLoad := false
+	for _, p := range f.Segments {
+		hasLoad = hasLoad || p.Type == elf.PT_LOAD
+	}
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NULL || s.Type == elf.SHT_NOBITS || s.Size == 0 || s.dirty || s.added {
+			continue
+		}
+		loaded := false
+		for i, p := range f.Segments {
+			if p.Filesz == 0 {
+				continue
+			}
+			inside := s.Offset >= p.Off && s.Offset+s.Size <= p.Off+p.Filesz
+			overlaps := s.Offset < p.Off+p.Filesz && s.Offset+s.Size > p.Off
+			if overlaps && !inside {
+				return formatErrorf(s.Offset, "sh_offset", "section %s straddles the boundary of segment %d", s.Name, i)
+			}
+			loaded = loaded || (inside && p.Type == elf.PT_LOAD)
+		}
+		if hasLoad && s.Flags&elf.SHF_ALLOC != 0 && !loaded {
+			return formatErrorf(s.Offset, "sh_offset", "allocated section %s is not covered by any PT_LOAD segment", s.Name)
+		}
+	}
+	return nil
+}
+
+// ListSegments returns the program headers present in the provided ELF data.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//
+// Returns:
+//   - A slice containing every program header in table order.
+//   - An error if the ELF data is invalid or cannot be parsed.
+func ListSegments(elfData []byte) ([]elf.ProgHeader, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	progs := make([]elf.ProgHeader, 0, len(f.Segments))
+	for _, seg := range f.Segments {
+		progs = append(progs, seg.ProgHeader)
+	}
+	return progs, nil
+}
@@ -0,0 +1,131 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestSegmentSections(t *testing.T) {
+	_, f := buildFile(t, testBuilders()["x86_64/exec"])
+	want := map[elf.ProgType][]string{
+		elf.PT_PHDR:      nil,
+		elf.PT_LOAD:      {".text", ".note.test"},
+		elf.PT_NOTE:      {".note.test"},
+		elf.PT_GNU_STACK: nil,
+	}
+	var loads [][]string
+	for _, seg := range f.Segments {
+		var names []string
+		for _, s := range f.SegmentSections(seg) {
+			names = append(names, s.Name)
+		}
+		if seg.Type == elf.PT_LOAD {
+			loads = append(loads, names)
+			continue
+		}
+		if !slices.Equal(names, want[seg.Type]) {
+			t.Errorf("%v covers %q, want %q", seg.Type, names, want[seg.Type])
+		}
+	}
+	// .bss only shares the memory range of the second PT_LOAD, .comment neither
+	if len(loads) != 2 || !slices.Equal(loads[0], want[elf.PT_LOAD]) || !slices.Equal(loads[1], []string{".data", ".bss"}) {
+		t.Errorf("PT_LOAD segments cover %q", loads)
+	}
+}
+
+func TestSegmentEdits(t *testing.T) {
+	data, f := buildFile(t, testBuilders()["x86_64/exec"])
+	var text, bss int
+	for i, seg := range f.Segments {
+		if seg.Type == elf.PT_LOAD && text == 0 {
+			text = i
+		} else if seg.Type == elf.PT_LOAD {
+			bss = i
+		}
+	}
+	load := f.Segments[bss].ProgHeader
+	next := load
+	next.Vaddr = alignUp(load.Vaddr+load.Memsz, 0x1000) + load.Off%0x1000
+	next.Paddr = next.Vaddr
+
+	tests := []struct {
+		name  string
+		edit  func() error
+		check error
+	}{
+		{"misaligned address", func() error {
+			p := next
+			p.Vaddr += 8
+			_, err := f.AddSegment(p)
+			return err
+		}, ErrInvalidArgument},
+		{"alignment not a power of two", func() error {
+			p := next
+			p.Align = 0x1800
+			_, err := f.AddSegment(p)
+			return err
+		}, ErrInvalidArgument},
+		{"file size beyond memory size", func() error {
+			p := next
+			p.Memsz = p.Filesz - 1
+			_, err := f.AddSegment(p)
+			return err
+		}, ErrInvalidArgument},
+		{"overlapping PT_LOAD", func() error {
+			p := next
+			p.Vaddr -= 0x1000
+			_, err := f.AddSegment(p)
+			return err
+		}, ErrInvalidArgument},
+		{"update into overlap", func() error {
+			p := f.Segments[text].ProgHeader
+			p.Memsz = load.Vaddr + 1 - p.Vaddr
+			return f.UpdateSegment(text, p)
+		}, ErrInvalidArgument},
+		{"section left outside", func() error {
+			p := load
+			p.Filesz, p.Memsz = 1, 1
+			return f.UpdateSegment(bss, p)
+		}, ErrInvalidArgument},
+		{"update unknown", func() error { return f.UpdateSegment(len(f.Segments), load) }, ErrSegmentNotFound},
+		{"remove unknown", func() error { return f.RemoveSegment(-1) }, ErrSegmentNotFound},
+	}
+	for _, tt := range tests {
+		if err := tt.edit(); !errors.Is(err, tt.check) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.check)
+		}
+	}
+	if out, err := f.Bytes(); err != nil || !bytes.Equal(out, data) {
+		t.Fatalf("refused segment edits changed the file: %v", err)
+	}
+
+	// A valid PT_LOAD goes after the existing ones and grows the table
+	seg, err := f.AddSegment(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := slices.Index(f.Segments, seg); i != bss+1 {
+		t.Errorf("new PT_LOAD at index %d, want %d", i, bss+1)
+	}
+	if err := f.RemoveSegment(len(f.Segments) - 1); err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ef, err := elf.NewFile(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progs []elf.ProgHeader
+	for _, p := range ef.Progs {
+		progs = append(progs, p.ProgHeader)
+	}
+	if len(progs) != len(f.Segments) || progs[bss+1] != next || progs[len(progs)-1].Type != elf.PT_NOTE {
+		t.Errorf("written program headers = %+v", progs)
+	}
+}
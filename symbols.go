@@ -0,0 +1,370 @@
+package elfy
+
+import (
+	"debug/elf"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a decoded entry of a symbol table.
+type Symbol struct {
+	Index      int // Position in the symbol table
+	Name       string
+	Value      uint64
+	Size       uint64
+	Bind       elf.SymBind
+	Type       elf.SymType
+	Visibility elf.SymVis
+	Shndx      elf.SectionIndex // Section index, resolved through SHT_SYMTAB_SHNDX; may be special, e.g. SHN_ABS
+	Section    string           // Name of the section at Shndx, or "" for special indices
+	Version    string           // GNU version, e.g. "GLIBC_2.34", or "" if the symbol is unversioned
+	Library    string           // Shared library the version is required from, e.g. "libc.so.6"
+	Hidden     bool             // The version is hidden: name@version rather than the default name@@version
+}
+
+// String returns the symbol's name with its version in the notation of
+// readelf, e.g. "printf@GLIBC_2.2.5" or "foo@@VERS_1".
+func (sym Symbol) String() string {
+	switch {
+	case sym.Version == "":
+		return sym.Name
+	case sym.Hidden || sym.Library != "":
+		return sym.Name + "@" + sym.Version
+	}
+	return sym.Name + "@@" + sym.Version
+}
+
+// Defined reports whether the symbol is defined in the file, i.e. whether its section index is not SHN_UNDEF.
+func (sym Symbol) Defined() bool {
+	return sym.Shndx != elf.SHN_UNDEF
+}
+
+// stbGNUUnique is the GNU binding of symbols unique in the whole process, which debug/elf calls STB_LOOS.
+const stbGNUUnique = elf.STB_LOOS
+
+// verFlagBase marks the version definition of the file itself (VER_FLG_BASE).
+const verFlagBase = 0x1
+
+// symbolVersion is a version referenced from .gnu.version.
+type symbolVersion struct {
+	name    string
+	library string // File name of the shared library, for required versions
+}
+
+// Symbols returns the entries of the static symbol table (SHT_SYMTAB,
+// usually .symtab), without the null symbol at index 0.
+//
+// Returns:
+//   - The symbols in table order.
+//   - ErrNoSymbols if the file has no static symbol table, or an error if it is malformed.
+func (f *File) Symbols() ([]Symbol, error) {
+	return f.symbolsOfType(elf.SHT_SYMTAB)
+}
+
+// DynamicSymbols returns the entries of the dynamic symbol table (SHT_DYNSYM,
+// usually .dynsym), without the null symbol at index 0. Versions are read
+// from .gnu.version, .gnu.version_d and .gnu.version_r.
+//
+// Returns:
+//   - The symbols in table order.
+//   - ErrNoSymbols if the file has no dynamic symbol table, or an error if it or the version sections are malformed.
+func (f *File) DynamicSymbols() ([]Symbol, error) {
+	return f.symbolsOfType(elf.SHT_DYNSYM)
+}
+
+// symbolsOfType decodes the first symbol table of the given type.
+func (f *File) symbolsOfType(typ elf.SectionType) ([]Symbol, error) {
+	for i, s := range f.Sections {
+		if s.Type == typ {
+			return f.symbols(i)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoSymbols, typ)
+}
+
+// symbols decodes the symbol table at index i, including the versions of an
+// associated SHT_GNU_VERSYM table.
+func (f *File) symbols(i int) ([]Symbol, error) {
+	s := f.Sections[i]
+	data, err := s.Data()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", s.Name, err)
+	}
+	var strtab []byte
+	if int(s.Link) < len(f.Sections) && s.Link != 0 {
+		if strtab, err = f.Sections[s.Link].Data(); err != nil {
+			return nil, fmt.Errorf("error reading string table of %s: %w", s.Name, err)
+		}
+	}
+	var xdata []byte
+	if xs := f.symtabShndx(i); xs != nil {
+		if xdata, err = xs.Data(); err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", xs.Name, err)
+		}
+	}
+	versyms, versions, err := f.symbolVersions(i)
+	if err != nil {
+		return nil, err
+	}
+
+	entSize := f.symbolSize()
+	if len(data)%entSize != 0 {
+		return nil, formatErrorf(s.Offset, "sh_size", "size of %s is not a multiple of %d", s.Name, entSize)
+	}
+	var syms []Symbol
+	for off := entSize; off+entSize <= len(data); off += entSize {
+		n := off / entSize
+		e := f.readSymbol(data[off : off+entSize])
+		sym := Symbol{
+			Index:      n,
+			Name:       getString(strtab, int(e.name)),
+			Value:      e.value,
+			Size:       e.size,
+			Bind:       elf.ST_BIND(e.info),
+			Type:       elf.ST_TYPE(e.info),
+			Visibility: elf.ST_VISIBILITY(e.other),
+			Shndx:      elf.SectionIndex(e.shndx),
+		}
+		if sym.Shndx == elf.SHN_XINDEX && n*4+4 <= len(xdata) {
+			sym.Shndx = elf.SectionIndex(f.ByteOrder.Uint32(xdata[n*4:]))
+		}
+		if sym.Shndx != elf.SHN_UNDEF && (sym.Shndx < elf.SHN_LORESERVE || sym.Shndx > elf.SHN_HIRESERVE) && int(sym.Shndx) < len(f.Sections) {
+			sym.Section = f.Sections[sym.Shndx].Name
+		}
+		if n*2+2 <= len(versyms) {
+			v := f.ByteOrder.Uint16(versyms[n*2:])
+			if ver, ok := versions[v&0x7fff]; ok {
+				sym.Version, sym.Library = ver.name, ver.library
+				sym.Hidden = v&0x8000 != 0
+			}
+		}
+		syms = append(syms, sym)
+	}
+	return syms, nil
+}
+
+// readSymbol decodes a single class-specific symbol table entry.
+func (f *File) readSymbol(entry []byte) symbolEntry {
+	order := f.ByteOrder
+	if f.Class == elf.ELFCLASS64 {
+		return symbolEntry{
+			name:  order.Uint32(entry[0:]),
+			info:  entry[4],
+			other: entry[5],
+			shndx: order.Uint16(entry[6:]),
+			value: order.Uint64(entry[8:]),
+			size:  order.Uint64(entry[16:]),
+		}
+	}
+	return symbolEntry{
+		name:  order.Uint32(entry[0:]),
+		value: uint64(order.Uint32(entry[4:])),
+		size:  uint64(order.Uint32(entry[8:])),
+		info:  entry[12],
+		other: entry[13],
+		shndx: order.Uint16(entry[14:]),
+	}
+}
+
+// symbolVersions returns the contents of the SHT_GNU_VERSYM table that
+// belongs to the symbol table at index i, and the versions its entries refer
+// to by index. Both are empty if the table has no versions.
+func (f *File) symbolVersions(i int) ([]byte, map[uint16]symbolVersion, error) {
+	var versyms []byte
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_GNU_VERSYM && int(s.Link) == i {
+			data, err := s.Data()
+			if err != nil {
+				return nil, nil, fmt.Errorf("error reading %s: %w", s.Name, err)
+			}
+			versyms = data
+			break
+		}
+	}
+	if versyms == nil {
+		return nil, nil, nil
+	}
+	versions := make(map[uint16]symbolVersion)
+	for _, s := range f.Sections {
+		var err error
+		switch s.Type {
+		case elf.SHT_GNU_VERDEF:
+			err = f.readVerdef(s, versions)
+		case elf.SHT_GNU_VERNEED:
+			err = f.readVerneed(s, versions)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return versyms, versions, nil
+}
+
+// readVerdef adds the versions defined by an SHT_GNU_VERDEF section to versions.
+func (f *File) readVerdef(s *Section, versions map[uint16]symbolVersion) error {
+	data, strtab, err := f.versionData(s)
+	if err != nil {
+		return err
+	}
+	order := f.ByteOrder
+	size := uint64(len(data))
+	off := uint64(0)
+	// Every entry takes at least 20 bytes, which bounds the walk even if vd_next loops
+	for range size / 20 {
+		if !inBounds(off, 20, size) {
+			return formatErrorf(s.Offset+off, "vd_next", "version definition out of bounds in %s", s.Name)
+		}
+		flags := order.Uint16(data[off+2:])
+		ndx := order.Uint16(data[off+4:])
+		cnt := order.Uint16(data[off+6:])
+		aux := off + uint64(order.Uint32(data[off+12:]))
+		next := order.Uint32(data[off+16:])
+		// The base definition names the file itself, not a version
+		if cnt > 0 && flags&verFlagBase == 0 {
+			if !inBounds(aux, 8, size) {
+				return formatErrorf(s.Offset+off, "vd_aux", "version definition name out of bounds in %s", s.Name)
+			}
+			versions[ndx] = symbolVersion{name: getString(strtab, int(order.Uint32(data[aux:])))}
+		}
+		if next == 0 {
+			return nil
+		}
+		off += uint64(next)
+	}
+	return nil
+}
+
+// readVerneed adds the versions required by an SHT_GNU_VERNEED section to versions.
+func (f *File) readVerneed(s *Section, versions map[uint16]symbolVersion) error {
+	data, strtab, err := f.versionData(s)
+	if err != nil {
+		return err
+	}
+	order := f.ByteOrder
+	size := uint64(len(data))
+	off := uint64(0)
+	// Entries and their auxiliary entries take 16 bytes each, which bounds both walks
+	for range size / 16 {
+		if !inBounds(off, 16, size) {
+			return formatErrorf(s.Offset+off, "vn_next", "version requirement out of bounds in %s", s.Name)
+		}
+		cnt := order.Uint16(data[off+2:])
+		file := getString(strtab, int(order.Uint32(data[off+4:])))
+		aux := off + uint64(order.Uint32(data[off+8:]))
+		next := order.Uint32(data[off+12:])
+		for range min(uint64(cnt), size/16) {
+			if !inBounds(aux, 16, size) {
+				return formatErrorf(s.Offset+off, "vn_aux", "version requirement out of bounds in %s", s.Name)
+			}
+			other := order.Uint16(data[aux+6:])
+			versions[other&0x7fff] = symbolVersion{
+				name:    getString(strtab, int(order.Uint32(data[aux+8:]))),
+				library: file,
+			}
+			vnaNext := order.Uint32(data[aux+12:])
+			if vnaNext == 0 {
+				break
+			}
+			aux += uint64(vnaNext)
+		}
+		if next == 0 {
+			return nil
+		}
+		off += uint64(next)
+	}
+	return nil
+}
+
+// versionData returns the contents of a version section and of its linked string table.
+func (f *File) versionData(s *Section) ([]byte, []byte, error) {
+	data, err := s.Data()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %w", s.Name, err)
+	}
+	if int(s.Link) >= len(f.Sections) {
+		return nil, nil, formatErrorf(s.Offset, "sh_link", "string table of %s out of range", s.Name)
+	}
+	strtab, err := f.Sections[s.Link].Data()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading string table of %s: %w", s.Name, err)
+	}
+	return data, strtab, nil
+}
+
+// ParseSymbolBind parses a symbol binding given by name, with or without the
+// STB_ prefix and in any case (e.g. "global", "STB_WEAK"), or as a number.
+// "unique" is accepted for STB_GNU_UNIQUE.
+func ParseSymbolBind(s string) (elf.SymBind, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	for _, b := range []elf.SymBind{elf.STB_LOCAL, elf.STB_GLOBAL, elf.STB_WEAK} {
+		if b.String() == name || b.String() == "STB_"+name {
+			return b, nil
+		}
+	}
+	switch name {
+	case "UNIQUE", "GNU_UNIQUE", "STB_GNU_UNIQUE":
+		return stbGNUUnique, nil
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 0, 4)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unknown symbol binding %q", ErrInvalidArgument, s)
+	}
+	return elf.SymBind(n), nil
+}
+
+// ParseSymbolType parses a symbol type given by name, with or without the
+// STT_ prefix and in any case (e.g. "func", "STT_OBJECT"), or as a number.
+// "ifunc" is accepted for STT_GNU_IFUNC.
+func ParseSymbolType(s string) (elf.SymType, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	for _, t := range []elf.SymType{
+		elf.STT_NOTYPE, elf.STT_OBJECT, elf.STT_FUNC, elf.STT_SECTION, elf.STT_FILE,
+		elf.STT_COMMON, elf.STT_TLS,
+	} {
+		if t.String() == name || t.String() == "STT_"+name {
+			return t, nil
+		}
+	}
+	switch name {
+	case "IFUNC", "GNU_IFUNC", "STT_GNU_IFUNC":
+		return elf.STT_GNU_IFUNC, nil
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 0, 4)
+	if err != nil {
+		return 0, fmt.Errorf("%w: unknown symbol type %q", ErrInvalidArgument, s)
+	}
+	return elf.SymType(n), nil
+}
+
+// ReadSymbols returns the static symbols of the provided ELF data; see File.Symbols.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//
+// Returns:
+//   - A slice of Symbol in table order.
+//   - An error if the ELF data is invalid or has no static symbol table.
+func ReadSymbols(elfData []byte) ([]Symbol, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	return f.Symbols()
+}
+
+// ReadDynamicSymbols returns the dynamic symbols of the provided ELF data; see File.DynamicSymbols.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//
+// Returns:
+//   - A slice of Symbol in table order.
+//   - An error if the ELF data is invalid or has no dynamic symbol table.
+func ReadDynamicSymbols(elfData []byte) ([]Symbol, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	return f.DynamicSymbols()
+}
@@ -0,0 +1,77 @@
+package elfy
+
+import (
+	"debug/elf"
+	"errors"
+	"testing"
+)
+
+func TestSymbols(t *testing.T) {
+	for name, b := range testBuilders() {
+		t.Run(name, func(t *testing.T) {
+			data, err := b.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			syms, err := ReadSymbols(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(syms) != len(b.Symbols) {
+				t.Fatalf("got %d symbols, want %d", len(syms), len(b.Symbols))
+			}
+			for i, sym := range syms {
+				if sym.Index != i+1 {
+					t.Errorf("symbol %s has index %d, want %d", sym.Name, sym.Index, i+1)
+				}
+				j := -1
+				for k, bs := range b.Symbols {
+					if bs.Name == sym.Name {
+						j = k
+					}
+				}
+				if j == -1 {
+					t.Errorf("unexpected symbol %s", sym.Name)
+					continue
+				}
+				bs := b.Symbols[j]
+				if sym.Bind != bs.Bind || sym.Type != bs.Type || sym.Size != bs.Size || sym.Section != bs.Section {
+					t.Errorf("symbol %s = %+v, want %+v", sym.Name, sym, bs)
+				}
+				if bs.Shndx != 0 && sym.Shndx != bs.Shndx {
+					t.Errorf("symbol %s has section index %v, want %v", sym.Name, sym.Shndx, bs.Shndx)
+				}
+			}
+			if _, err := ReadDynamicSymbols(data); !errors.Is(err, ErrNoSymbols) {
+				t.Errorf("dynamic symbols: error = %v, want %v", err, ErrNoSymbols)
+			}
+		})
+	}
+}
+
+func TestDynamicSymbolVersions(t *testing.T) {
+	data, _ := readFile(t, "tiny64")
+	syms, err := ReadDynamicSymbols(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, sym := range syms {
+		if sym.Name != "__libc_start_main" {
+			continue
+		}
+		found = true
+		if sym.Version != "GLIBC_2.34" || sym.Library != "libc.so.6" || sym.Defined() || sym.Type != elf.STT_FUNC {
+			t.Errorf("__libc_start_main = %+v", sym)
+		}
+		if s := sym.String(); s != "__libc_start_main@GLIBC_2.34" {
+			t.Errorf("String() = %q", s)
+		}
+	}
+	if !found {
+		t.Error("__libc_start_main not found")
+	}
+	if _, err := ReadSymbols(data); !errors.Is(err, ErrNoSymbols) {
+		t.Errorf("static symbols: error = %v, want %v", err, ErrNoSymbols)
+	}
+}
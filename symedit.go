@@ -0,0 +1,568 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+// editSymbol is a symbol table entry being edited.
+type editSymbol struct {
+	name  string
+	entry symbolEntry
+	shndx uint32 // Section index, resolved through SHT_SYMTAB_SHNDX when entry.shndx is SHN_XINDEX
+	orig  int    // Index in the parsed table, or -1 for added symbols
+}
+
+func (sym *editSymbol) bind() elf.SymBind { return elf.ST_BIND(sym.entry.info) }
+func (sym *editSymbol) typ() elf.SymType  { return elf.ST_TYPE(sym.entry.info) }
+
+func (sym *editSymbol) setBind(bind elf.SymBind) {
+	sym.entry.info = elf.ST_INFO(bind, sym.typ())
+}
+
+// defined reports whether the symbol is defined in the file.
+func (sym *editSymbol) defined() bool {
+	return sym.shndx != uint32(elf.SHN_UNDEF)
+}
+
+// SymbolEdits lists objcopy-style changes to the static symbol table; see File.EditSymbols.
+type SymbolEdits struct {
+	Remove     []string          // Symbols to remove, like --strip-symbol
+	Rename     map[string]string // New names keyed by current name, like --redefine-sym
+	Localize   []string          // Symbols to make local, like --localize-symbol
+	KeepGlobal []string          // If not empty, the only defined symbols that stay global, like --keep-global-symbol
+	Globalize  []string          // Symbols to make global, like --globalize-symbol
+	Weaken     []string          // Symbols to make weak, like --weaken-symbol
+	Prefix     string            // Prefix for every symbol name, like --prefix-symbols
+	Add        []Symbol          // Symbols to add after all other changes, like --add-symbol
+}
+
+// EditSymbols applies a set of changes to the static symbol table in the
+// order of the SymbolEdits fields, so for example KeepGlobal and Prefix see
+// the names given by Rename. Either every change takes effect or none.
+//
+// Parameters:
+//   - edits: The changes to make.
+//
+// Returns:
+//   - An error naming the change that failed.
+func (f *File) EditSymbols(edits SymbolEdits) error {
+	type step struct {
+		name string
+		do   func() error
+	}
+	var steps []step
+	if len(edits.Remove) > 0 {
+		steps = append(steps, step{"remove", func() error { return f.RemoveSymbols(edits.Remove...) }})
+	}
+	if len(edits.Rename) > 0 {
+		steps = append(steps, step{"rename", func() error { return f.RenameSymbols(edits.Rename) }})
+	}
+	if len(edits.Localize) > 0 {
+		steps = append(steps, step{"localize", func() error { return f.LocalizeSymbols(edits.Localize...) }})
+	}
+	if len(edits.KeepGlobal) > 0 {
+		steps = append(steps, step{"keep global", func() error { return f.KeepGlobalSymbols(edits.KeepGlobal...) }})
+	}
+	if len(edits.Globalize) > 0 {
+		steps = append(steps, step{"globalize", func() error { return f.GlobalizeSymbols(edits.Globalize...) }})
+	}
+	if len(edits.Weaken) > 0 {
+		steps = append(steps, step{"weaken", func() error { return f.WeakenSymbols(edits.Weaken...) }})
+	}
+	if edits.Prefix != "" {
+		steps = append(steps, step{"prefix", func() error { return f.PrefixSymbols(edits.Prefix) }})
+	}
+	for _, sym := range edits.Add {
+		steps = append(steps, step{"add " + sym.Name, func() error { return f.AddSymbol(sym) }})
+	}
+
+	saved := f.snapshot()
+	for _, st := range steps {
+		if err := st.do(); err != nil {
+			f.restore(saved)
+			return fmt.Errorf("%s: %w", st.name, err)
+		}
+	}
+	return nil
+}
+
+// AddSymbol adds a symbol to the static symbol table.
+//
+// Parameters:
+//   - sym: The symbol to add. Section, if set, takes precedence over Shndx;
+//     Index, Version, Library and Hidden are ignored.
+//
+// Returns:
+//   - An error if a global or weak symbol of the same name exists, the section is unknown or there is no symbol table.
+func (f *File) AddSymbol(sym Symbol) error {
+	if sym.Name == "" {
+		return fmt.Errorf("%w: missing symbol name", ErrInvalidArgument)
+	}
+	shndx := uint32(sym.Shndx)
+	if sym.Section != "" {
+		s := f.Section(sym.Section)
+		if s == nil {
+			return fmt.Errorf("%w: %s", ErrSectionNotFound, sym.Section)
+		}
+		shndx = uint32(s.Index())
+	}
+	stored := uint16(shndx)
+	if sym.Section != "" && shndx >= uint32(elf.SHN_LORESERVE) {
+		stored = uint16(elf.SHN_XINDEX)
+	}
+	return f.editSymbols(func(syms []editSymbol) ([]editSymbol, error) {
+		if sym.Bind != elf.STB_LOCAL && slices.ContainsFunc(syms, func(s editSymbol) bool { return s.name == sym.Name && s.bind() != elf.STB_LOCAL }) {
+			return nil, fmt.Errorf("%w: %s", ErrSymbolExists, sym.Name)
+		}
+		e := editSymbol{
+			name: sym.Name,
+			entry: symbolEntry{
+				info:  elf.ST_INFO(sym.Bind, sym.Type),
+				other: byte(sym.Visibility) & 0x3,
+				shndx: stored,
+				value: sym.Value,
+				size:  sym.Size,
+			},
+			shndx: shndx,
+			orig:  -1,
+		}
+		return append(syms, e), nil
+	})
+}
+
+// RemoveSymbols removes every symbol with one of the given names from the
+// static symbol table.
+//
+// Parameters:
+//   - names: The names of the symbols to remove.
+//
+// Returns:
+//   - ErrSymbolNotFound if a name matches no symbol, ErrSymbolReferenced if a
+//     relocation or section group still refers to one of the symbols.
+func (f *File) RemoveSymbols(names ...string) error {
+	return f.editSymbols(func(syms []editSymbol) ([]editSymbol, error) {
+		if err := checkSymbolNames(syms, names); err != nil {
+			return nil, err
+		}
+		return slices.DeleteFunc(syms, func(s editSymbol) bool { return slices.Contains(names, s.name) }), nil
+	})
+}
+
+// RenameSymbol renames every symbol called oldName in the static symbol table.
+//
+// Parameters:
+//   - oldName: The current name.
+//   - newName: The new name.
+//
+// Returns:
+//   - ErrSymbolNotFound if no symbol is called oldName, ErrSymbolExists if a
+//     global or weak symbol would share its name with another one.
+func (f *File) RenameSymbol(oldName, newName string) error {
+	return f.RenameSymbols(map[string]string{oldName: newName})
+}
+
+// RenameSymbols renames symbols of the static symbol table, like objcopy
+// --redefine-sym. All renames apply at once, so two symbols can swap names.
+//
+// Parameters:
+//   - renames: The new name of each symbol, keyed by its current name.
+//
+// Returns:
+//   - ErrSymbolNotFound if no symbol has one of the current names,
+//     ErrSymbolExists if a global or weak symbol would share its name with another one.
+func (f *File) RenameSymbols(renames map[string]string) error {
+	oldNames := make([]string, 0, len(renames))
+	for oldName, newName := range renames {
+		if newName == "" {
+			return fmt.Errorf("%w: missing new name for symbol %s", ErrInvalidArgument, oldName)
+		}
+		oldNames = append(oldNames, oldName)
+	}
+	slices.Sort(oldNames)
+	return f.editSymbols(func(syms []editSymbol) ([]editSymbol, error) {
+		if err := checkSymbolNames(syms, oldNames); err != nil {
+			return nil, err
+		}
+		for i := range syms {
+			if newName, ok := renames[syms[i].name]; ok {
+				syms[i].name = newName
+			}
+		}
+		for _, oldName := range oldNames {
+			if err := checkSymbolClash(syms, renames[oldName], false); err != nil {
+				return nil, err
+			}
+		}
+		return syms, nil
+	})
+}
+
+// PrefixSymbols prepends prefix to the name of every named symbol of the
+// static symbol table, defined or not, like objcopy --prefix-symbols.
+// Section and file symbols are left alone.
+//
+// Parameters:
+//   - prefix: The string to prepend.
+//
+// Returns:
+//   - An error if the file has no symbol table.
+func (f *File) PrefixSymbols(prefix string) error {
+	return f.editSymbols(func(syms []editSymbol) ([]editSymbol, error) {
+		for i := range syms {
+			if syms[i].name != "" && syms[i].typ() != elf.STT_SECTION && syms[i].typ() != elf.STT_FILE {
+				syms[i].name = prefix + syms[i].name
+			}
+		}
+		return syms, nil
+	})
+}
+
+// LocalizeSymbols makes the defined symbols with the given names local.
+//
+// Parameters:
+//   - names: The names of the symbols.
+//
+// Returns:
+//   - ErrSymbolNotFound if a name matches no symbol, or an error if one of
+//     them is undefined and so cannot be local.
+func (f *File) LocalizeSymbols(names ...string) error {
+	return f.setBinding(names, elf.STB_LOCAL)
+}
+
+// GlobalizeSymbols makes the defined symbols with the given names global.
+//
+// Parameters:
+//   - names: The names of the symbols.
+//
+// Returns:
+//   - ErrSymbolNotFound if a name matches no symbol, ErrSymbolExists if two
+//     global symbols would share a name.
+func (f *File) GlobalizeSymbols(names ...string) error {
+	return f.setBinding(names, elf.STB_GLOBAL)
+}
+
+// WeakenSymbols makes the global symbols with the given names weak, so that
+// definitions elsewhere take precedence. Local symbols are left alone.
+//
+// Parameters:
+//   - names: The names of the symbols.
+//
+// Returns:
+//   - ErrSymbolNotFound if a name matches no symbol.
+func (f *File) WeakenSymbols(names ...string) error {
+	return f.setBinding(names, elf.STB_WEAK)
+}
+
+// KeepGlobalSymbols makes every defined global or weak symbol local unless
+// its name is in keep, like objcopy --keep-global-symbol. Undefined symbols
+// stay global so they can still be resolved.
+//
+// Parameters:
+//   - keep: The names of the symbols that stay global.
+//
+// Returns:
+//   - An error if the file has no symbol table.
+func (f *File) KeepGlobalSymbols(keep ...string) error {
+	return f.editSymbols(func(syms []editSymbol) ([]editSymbol, error) {
+		for i := range syms {
+			if syms[i].bind() != elf.STB_LOCAL && syms[i].defined() && !slices.Contains(keep, syms[i].name) {
+				syms[i].setBind(elf.STB_LOCAL)
+			}
+		}
+		return syms, nil
+	})
+}
+
+// setBinding changes the binding of the symbols with the given names.
+func (f *File) setBinding(names []string, bind elf.SymBind) error {
+	return f.editSymbols(func(syms []editSymbol) ([]editSymbol, error) {
+		if err := checkSymbolNames(syms, names); err != nil {
+			return nil, err
+		}
+		for i := range syms {
+			sym := &syms[i]
+			if !slices.Contains(names, sym.name) {
+				continue
+			}
+			switch bind {
+			case elf.STB_LOCAL:
+				if !sym.defined() {
+					return nil, fmt.Errorf("%w: undefined symbol %s cannot be local", ErrInvalidArgument, sym.name)
+				}
+			case elf.STB_GLOBAL:
+				if !sym.defined() && sym.bind() == elf.STB_LOCAL {
+					continue
+				}
+			case elf.STB_WEAK:
+				if sym.bind() == elf.STB_LOCAL {
+					continue
+				}
+			}
+			sym.setBind(bind)
+		}
+		if bind == elf.STB_GLOBAL {
+			// Locals of the same name, e.g. static functions, must not become duplicate definitions
+			for _, name := range names {
+				if err := checkSymbolClash(syms, name, true); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return syms, nil
+	})
+}
+
+// checkSymbolClash returns ErrSymbolExists if more than one global or weak
+// symbol is called name, counting only defined symbols if definedOnly is set.
+func checkSymbolClash(syms []editSymbol, name string, definedOnly bool) error {
+	n := 0
+	for _, sym := range syms {
+		if sym.name == name && sym.bind() != elf.STB_LOCAL && (sym.defined() || !definedOnly) {
+			n++
+		}
+	}
+	if n > 1 {
+		return fmt.Errorf("%w: %d global symbols called %s", ErrSymbolExists, n, name)
+	}
+	return nil
+}
+
+// checkSymbolNames returns ErrSymbolNotFound for the first name no symbol has.
+func checkSymbolNames(syms []editSymbol, names []string) error {
+	for _, name := range names {
+		if !slices.ContainsFunc(syms, func(s editSymbol) bool { return s.name == name }) {
+			return fmt.Errorf("%w: %s", ErrSymbolNotFound, name)
+		}
+	}
+	return nil
+}
+
+// editSymbols decodes the static symbol table, lets edit change its entries
+// (without the null symbol) and writes the result back: local symbols are
+// placed first and sh_info is updated, the string table is rebuilt and the
+// symbol indices of relocations and section groups are renumbered. Nothing
+// changes if edit or any of the fix-ups fails.
+func (f *File) editSymbols(edit func(syms []editSymbol) ([]editSymbol, error)) error {
+	i := slices.IndexFunc(f.Sections, func(s *Section) bool { return s.Type == elf.SHT_SYMTAB })
+	if i == -1 {
+		return fmt.Errorf("%w: %s", ErrNoSymbols, elf.SHT_SYMTAB)
+	}
+	symtab := f.Sections[i]
+	if symtab.Link == 0 || int(symtab.Link) >= len(f.Sections) {
+		return formatErrorf(symtab.Offset, "sh_link", "string table of %s out of range", symtab.Name)
+	}
+	strtab := f.Sections[symtab.Link]
+	syms, err := f.decodeSymbols(i)
+	if err != nil {
+		return err
+	}
+	count := len(syms) + 1
+	origNames := make([]string, count)
+	for _, sym := range syms {
+		origNames[sym.orig] = sym.name
+	}
+	if syms, err = edit(syms); err != nil {
+		return err
+	}
+	slices.SortStableFunc(syms, func(a, b editSymbol) int {
+		return boolCompare(a.bind() != elf.STB_LOCAL, b.bind() != elf.STB_LOCAL)
+	})
+	newIndex := make([]int, count)
+	for j := range newIndex {
+		newIndex[j] = -1
+	}
+	newIndex[0] = 0
+	for j, sym := range syms {
+		if sym.orig != -1 {
+			newIndex[sym.orig] = j + 1
+		}
+	}
+
+	patches := make(map[*Section][]byte)
+	groups := make(map[*Section]uint32)
+	for _, s := range f.Sections {
+		if int(s.Link) != i {
+			continue
+		}
+		switch s.Type {
+		case elf.SHT_REL, elf.SHT_RELA:
+			data, err := f.renumberRelocations(s, newIndex, origNames)
+			if err != nil {
+				return err
+			}
+			if data != nil {
+				patches[s] = data
+			}
+		case elf.SHT_GROUP:
+			if int(s.Info) >= count || newIndex[s.Info] == -1 {
+				return fmt.Errorf("%w: %s as signature of section group %s", ErrSymbolReferenced, origNames[min(int(s.Info), count-1)], s.Name)
+			}
+			groups[s] = uint32(newIndex[s.Info])
+		}
+	}
+
+	// Reuse a string table shared with other sections by appending names to it
+	names := stringTable{}
+	if strtab == f.shstrtab || slices.ContainsFunc(f.Sections, func(s *Section) bool {
+		return s != symtab && s.Type != elf.SHT_SYMTAB_SHNDX && s.Link == symtab.Link && s.Type != elf.SHT_NULL
+	}) {
+		if names.data, err = strtab.Data(); err != nil {
+			return fmt.Errorf("error reading %s: %w", strtab.Name, err)
+		}
+	}
+	xs := f.symtabShndx(i)
+	var buf bytes.Buffer
+	xbuf := make([]byte, 4*(len(syms)+1))
+	locals := 1
+	for j, sym := range append([]editSymbol{{}}, syms...) {
+		e := sym.entry
+		e.name = names.add(sym.name)
+		if e.shndx != uint16(elf.SHN_XINDEX) {
+			e.shndx = uint16(sym.shndx)
+		}
+		if e.shndx == uint16(elf.SHN_XINDEX) || sym.shndx > uint32(elf.SHN_HIRESERVE) {
+			if xs == nil {
+				return fmt.Errorf("%w: section index %d of symbol %s needs an SHT_SYMTAB_SHNDX table", ErrInvalidArgument, sym.shndx, sym.name)
+			}
+			e.shndx = uint16(elf.SHN_XINDEX)
+			f.ByteOrder.PutUint32(xbuf[j*4:], sym.shndx)
+		}
+		if err := f.writeSymbol(&buf, e); err != nil {
+			return err
+		}
+		if j > 0 && sym.bind() == elf.STB_LOCAL {
+			locals = j + 1
+		}
+	}
+
+	symtab.SetData(buf.Bytes())
+	symtab.Info = uint32(locals)
+	if xs != nil {
+		xs.SetData(xbuf)
+	}
+	strtab.SetData(names.data)
+	for s, data := range patches {
+		if err := s.rewrite(data); err != nil {
+			return err
+		}
+	}
+	for s, info := range groups {
+		s.Info = info
+	}
+	return nil
+}
+
+// decodeSymbols returns the entries of the symbol table at index i without the null symbol.
+func (f *File) decodeSymbols(i int) ([]editSymbol, error) {
+	s := f.Sections[i]
+	data, err := s.Data()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", s.Name, err)
+	}
+	strtab, err := f.Sections[s.Link].Data()
+	if err != nil {
+		return nil, fmt.Errorf("error reading string table of %s: %w", s.Name, err)
+	}
+	var xdata []byte
+	if xs := f.symtabShndx(i); xs != nil {
+		if xdata, err = xs.Data(); err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", xs.Name, err)
+		}
+	}
+	entSize := f.symbolSize()
+	if len(data)%entSize != 0 {
+		return nil, formatErrorf(s.Offset, "sh_size", "size of %s is not a multiple of %d", s.Name, entSize)
+	}
+	var syms []editSymbol
+	for off := entSize; off+entSize <= len(data); off += entSize {
+		n := off / entSize
+		e := f.readSymbol(data[off : off+entSize])
+		sym := editSymbol{name: getString(strtab, int(e.name)), entry: e, shndx: uint32(e.shndx), orig: n}
+		if e.shndx == uint16(elf.SHN_XINDEX) && n*4+4 <= len(xdata) {
+			sym.shndx = f.ByteOrder.Uint32(xdata[n*4:])
+		}
+		syms = append(syms, sym)
+	}
+	return syms, nil
+}
+
+// renumberRelocations rewrites the symbol indices of the SHT_REL or SHT_RELA
+// section s using newIndex. names holds the old symbol names for error
+// messages. It returns nil if nothing changes.
+func (f *File) renumberRelocations(s *Section, newIndex []int, names []string) ([]byte, error) {
+	data, err := s.Data()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", s.Name, err)
+	}
+	word := int(f.wordSize())
+	entSize := 2 * word
+	if s.Type == elf.SHT_RELA {
+		entSize = 3 * word
+	}
+	order := f.ByteOrder
+	// MIPS64 stores r_sym as the first 32-bit word of r_info in either byte order
+	mips64 := f.Class == elf.ELFCLASS64 && f.Machine == elf.EM_MIPS
+	changed := false
+	for off := 0; off+entSize <= len(data); off += entSize {
+		info := data[off+word : off+2*word]
+		var sym uint64
+		switch {
+		case word == 4:
+			sym = uint64(order.Uint32(info) >> 8)
+		case mips64:
+			sym = uint64(order.Uint32(info))
+		default:
+			sym = order.Uint64(info) >> 32
+		}
+		if sym == 0 {
+			continue
+		}
+		if sym >= uint64(len(newIndex)) || newIndex[sym] == -1 {
+			name := fmt.Sprintf("#%d", sym)
+			if sym < uint64(len(names)) && names[sym] != "" {
+				name = names[sym]
+			}
+			return nil, fmt.Errorf("%w: %s by relocation at offset 0x%x in %s", ErrSymbolReferenced, name, off, s.Name)
+		}
+		n := uint64(newIndex[sym])
+		if n == sym {
+			continue
+		}
+		switch {
+		case word == 4:
+			order.PutUint32(info, uint32(n)<<8|order.Uint32(info)&0xff)
+		case mips64:
+			order.PutUint32(info, uint32(n))
+		default:
+			order.PutUint64(info, n<<32|order.Uint64(info)&0xffffffff)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil, nil
+	}
+	return data, nil
+}
+
+// EditSymbols applies changes to the static symbol table of the ELF data; see File.EditSymbols.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//   - edits: The changes to make.
+//
+// Returns:
+//   - A byte slice containing the modified ELF file data.
+//   - An error if the ELF data is invalid or a change fails.
+func EditSymbols(elfData []byte, edits SymbolEdits) ([]byte, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.EditSymbols(edits); err != nil {
+		return nil, err
+	}
+	return f.Bytes()
+}
@@ -0,0 +1,130 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// relocationInfo stores sym in r_info when it is non-zero and returns the symbol r_info refers to.
+func relocationInfo(class elf.Class, machine elf.Machine, order binary.ByteOrder, info []byte, sym uint32) uint32 {
+	switch {
+	case class == elf.ELFCLASS32:
+		if sym != 0 {
+			order.PutUint32(info, sym<<8|1)
+		}
+		return order.Uint32(info) >> 8
+	case machine == elf.EM_MIPS:
+		if sym != 0 {
+			order.PutUint32(info, sym)
+		}
+		return order.Uint32(info)
+	}
+	if sym != 0 {
+		order.PutUint64(info, uint64(sym)<<32|1)
+	}
+	return uint32(order.Uint64(info) >> 32)
+}
+
+func TestEditSymbols(t *testing.T) {
+	for name, b := range testBuilders() {
+		if b.Type != elf.ET_REL {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			order := binary.ByteOrder(binary.LittleEndian)
+			if b.Data == elf.ELFDATA2MSB {
+				order = binary.BigEndian
+			}
+			word := 8
+			if b.Class == elf.ELFCLASS32 {
+				word = 4
+			}
+			// One relocation against blob_size, the second symbol
+			rela := make([]byte, 3*word)
+			relocationInfo(b.Class, b.Machine, order, rela[word:2*word], 2)
+			b.Sections = append(b.Sections, BuildSection{Name: ".rela.rodata", Type: elf.SHT_RELA, Flags: elf.SHF_INFO_LINK, Addralign: uint64(word), Entsize: uint64(3 * word), Link: ".symtab", InfoLink: ".rodata", Data: rela})
+			data, _ := buildFile(t, b)
+
+			out, err := EditSymbols(data, SymbolEdits{
+				Rename:     map[string]string{"blob_size": "vendor_blob_size"},
+				KeepGlobal: []string{"vendor_blob_size"},
+				Add:        []Symbol{{Name: "marker", Section: ".rodata", Value: 2, Bind: elf.STB_LOCAL}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := parseFile(t, out)
+			checkVerify(t, f)
+			syms, err := f.Symbols()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, sym := range syms {
+				names = append(names, sym.Name)
+			}
+			if want := []string{"blob", "marker", "vendor_blob_size"}; !slices.Equal(names, want) {
+				t.Fatalf("symbols = %q, want %q", names, want)
+			}
+			if syms[0].Bind != elf.STB_LOCAL || syms[1].Bind != elf.STB_LOCAL || syms[2].Bind != elf.STB_GLOBAL {
+				t.Errorf("bindings = %v %v %v", syms[0].Bind, syms[1].Bind, syms[2].Bind)
+			}
+			if syms[1].Section != ".rodata" || syms[1].Value != 2 {
+				t.Errorf("added symbol = %+v", syms[1])
+			}
+			if info := f.Section(".symtab").Info; info != 3 {
+				t.Errorf(".symtab sh_info = %d, want 3", info)
+			}
+			relData, err := f.Section(".rela.rodata").Data()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sym := relocationInfo(b.Class, b.Machine, order, relData[word:2*word], 0); sym != 3 {
+				t.Errorf("relocation refers to symbol %d, want 3", sym)
+			}
+
+			// A failing step leaves the file untouched
+			before, err := f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = f.EditSymbols(SymbolEdits{Weaken: []string{"vendor_blob_size"}, Remove: []string{"vendor_blob_size"}})
+			if !errors.Is(err, ErrSymbolReferenced) {
+				t.Errorf("removing referenced symbol: error = %v, want %v", err, ErrSymbolReferenced)
+			}
+			after, err := f.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(before, after) {
+				t.Error("failed edit changed the file")
+			}
+		})
+	}
+}
+
+func TestEditSymbolsErrors(t *testing.T) {
+	data, _ := buildFile(t, testBuilders()["x86_64/exec"])
+	tests := []struct {
+		name  string
+		edits SymbolEdits
+		check error
+	}{
+		{"missing", SymbolEdits{Localize: []string{"nope"}}, ErrSymbolNotFound},
+		{"rename clash", SymbolEdits{Rename: map[string]string{"buffer": "_start"}}, ErrSymbolExists},
+		{"globalize clash", SymbolEdits{Rename: map[string]string{"greeting": "_start"}, Globalize: []string{"_start"}}, ErrSymbolExists},
+		{"add clash", SymbolEdits{Add: []Symbol{{Name: "_start", Bind: elf.STB_GLOBAL, Shndx: elf.SHN_ABS}}}, ErrSymbolExists},
+		{"unknown section", SymbolEdits{Add: []Symbol{{Name: "x", Section: ".nope"}}}, ErrSectionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := EditSymbols(data, tt.edits); !errors.Is(err, tt.check) {
+				t.Errorf("error = %v, want %v", err, tt.check)
+			}
+		})
+	}
+}
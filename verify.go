@@ -0,0 +1,263 @@
+package elfy
+
+import (
+	"cmp"
+	"debug/elf"
+	"fmt"
+	"slices"
+)
+
+// Severity classifies a Problem found by Verify.
+type Severity int
+
+const (
+	// SeverityWarning marks layouts that are unusual but accepted by common tools.
+	SeverityWarning Severity = iota
+	// SeverityError marks layouts that loaders or other tools reject.
+	SeverityError
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Problem is a single structural issue reported by Verify.
+type Problem struct {
+	Severity Severity
+	Check    string // Identifier of the check that failed, e.g. "section-overlap"
+	Location string // The part of the file concerned, e.g. "section [3] .note.x"
+	Message  string
+}
+
+// String renders the problem as "severity: location: message [check]".
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", p.Severity, p.Location, p.Message, p.Check)
+}
+
+// Verify runs a catalog of structural checks on the file as it was parsed:
+// section and segment bounds, overlapping section file ranges, alignment of
+// offsets and addresses, section indices in sh_link and sh_info, names that
+// lie outside .shstrtab, allocated sections that no PT_LOAD segment maps, and
+// PT_LOAD congruence and ordering. Pending edits are not considered; to check
+// them, verify the output of Bytes with the Verify function.
+//
+// Returns:
+//   - The problems found, errors first, or nil if the file is sound.
+func (f *File) Verify() []Problem {
+	v := &verifier{f: f}
+	v.checkHeader()
+	v.checkSections()
+	v.checkOverlaps()
+	v.checkSegments()
+	v.checkMapping()
+	slices.SortStableFunc(v.problems, func(a, b Problem) int {
+		return cmp.Compare(b.Severity, a.Severity)
+	})
+	return v.problems
+}
+
+// verifier accumulates the problems found in a file.
+type verifier struct {
+	f        *File
+	problems []Problem
+}
+
+func (v *verifier) add(sev Severity, check, location, format string, args ...any) {
+	v.problems = append(v.problems, Problem{sev, check, location, fmt.Sprintf(format, args...)})
+}
+
+func sectionLocation(i int, s *Section) string {
+	return fmt.Sprintf("section [%d] %s", i, s.Name)
+}
+
+// checkHeader checks the section header string table referenced by the ELF header.
+func (v *verifier) checkHeader() {
+	f := v.f
+	if len(f.Sections) == 0 {
+		return
+	}
+	if f.shstrtab == nil {
+		v.add(SeverityError, "shstrndx", "ELF header", "no section header string table")
+		return
+	}
+	if f.shstrtab.Type != elf.SHT_STRTAB {
+		v.add(SeverityError, "shstrndx", "ELF header", "e_shstrndx refers to %s of type %v", f.shstrtab.Name, f.shstrtab.Type)
+	}
+}
+
+// checkSections checks every section header on its own.
+func (v *verifier) checkSections() {
+	f := v.f
+	size := uint64(len(f.raw))
+	var shstrtabSize uint64
+	if f.shstrtab != nil {
+		shstrtabSize = f.shstrtab.Size
+	}
+	for i, s := range f.Sections {
+		if i == 0 {
+			continue
+		}
+		loc := sectionLocation(i, s)
+		if f.shstrtab != nil && uint64(s.nameOff)+uint64(len(s.Name)) >= shstrtabSize {
+			v.add(SeverityError, "section-name", loc, "name at offset %d is not inside .shstrtab (%d bytes)", s.nameOff, shstrtabSize)
+		}
+		if s.Type != elf.SHT_NOBITS && (s.Offset > size || s.Size > size-s.Offset) {
+			v.add(SeverityError, "section-bounds", loc, "file range 0x%x-0x%x exceeds file size 0x%x", s.Offset, s.Offset+s.Size, size)
+		}
+		if s.Addralign > 1 && s.Addralign&(s.Addralign-1) != 0 {
+			v.add(SeverityError, "section-align", loc, "alignment %d is not a power of two", s.Addralign)
+		} else if s.Addralign > 1 {
+			if s.Flags&elf.SHF_ALLOC != 0 && s.Addr%s.Addralign != 0 {
+				v.add(SeverityError, "section-align", loc, "address 0x%x is not aligned to %d", s.Addr, s.Addralign)
+			}
+			if s.Type != elf.SHT_NOBITS && s.Offset%s.Addralign != 0 {
+				v.add(SeverityWarning, "section-align", loc, "offset 0x%x is not aligned to %d", s.Offset, s.Addralign)
+			}
+		}
+		if int(s.Link) >= len(f.Sections) {
+			v.add(SeverityError, "section-link", loc, "sh_link %d out of range", s.Link)
+		}
+		if s.infoIsSection() && int(s.Info) >= len(f.Sections) {
+			v.add(SeverityError, "section-info", loc, "sh_info %d out of range", s.Info)
+		}
+	}
+}
+
+// checkOverlaps reports file ranges that are claimed by more than one section
+// or by a section and one of the header tables.
+func (v *verifier) checkOverlaps() {
+	f := v.f
+	type claim struct {
+		extent
+		what string
+	}
+	claims := []claim{{extent{0, uint64(f.ehsize)}, "ELF header"}}
+	if f.phnum > 0 {
+		claims = append(claims, claim{extent{f.phoff, f.phoff + uint64(f.phnum)*uint64(f.phentsize)}, "program header table"})
+	}
+	if len(f.Sections) > 0 {
+		claims = append(claims, claim{extent{f.shoff, f.shoff + uint64(len(f.Sections))*uint64(f.shentsize)}, "section header table"})
+	}
+	for i, s := range f.Sections {
+		if s.Type != elf.SHT_NOBITS && s.Type != elf.SHT_NULL && s.Size > 0 {
+			claims = append(claims, claim{extent{s.Offset, s.Offset + s.Size}, sectionLocation(i, s)})
+		}
+	}
+	slices.SortStableFunc(claims, func(a, b claim) int {
+		return cmp.Compare(a.off, b.off)
+	})
+	furthest := 0
+	for i := 1; i < len(claims); i++ {
+		if claims[furthest].end > claims[i].off {
+			v.add(SeverityError, "section-overlap", claims[i].what, "file range 0x%x-0x%x overlaps %s", claims[i].off, claims[i].end, claims[furthest].what)
+		}
+		if claims[i].end > claims[furthest].end {
+			furthest = i
+		}
+	}
+}
+
+// checkSegments checks every program header and the order of PT_LOAD segments.
+func (v *verifier) checkSegments() {
+	f := v.f
+	size := uint64(len(f.raw))
+	var prev *Segment
+	for i, p := range f.Segments {
+		loc := fmt.Sprintf("segment %d (%v)", i, p.Type)
+		if p.Off > size || p.Filesz > size-p.Off {
+			v.add(SeverityError, "segment-bounds", loc, "file range 0x%x-0x%x exceeds file size 0x%x", p.Off, p.Off+p.Filesz, size)
+		}
+		if p.Type != elf.PT_LOAD {
+			continue
+		}
+		if p.Filesz > p.Memsz {
+			v.add(SeverityError, "segment-size", loc, "file size 0x%x exceeds memory size 0x%x", p.Filesz, p.Memsz)
+		}
+		if p.Align > 1 && p.Vaddr%p.Align != p.Off%p.Align {
+			v.add(SeverityError, "segment-align", loc, "address 0x%x and offset 0x%x are not congruent modulo 0x%x", p.Vaddr, p.Off, p.Align)
+		}
+		if prev != nil && p.Vaddr < prev.Vaddr {
+			v.add(SeverityError, "segment-order", loc, "PT_LOAD segments are not sorted by address")
+		} else if prev != nil && p.Vaddr < prev.Vaddr+prev.Memsz {
+			v.add(SeverityError, "segment-overlap", loc, "memory range overlaps the previous PT_LOAD segment")
+		}
+		prev = p
+	}
+	for i, p := range f.Segments {
+		if p.Type == elf.PT_PHDR && !v.loaded(p.Off, p.Vaddr, p.Filesz) {
+			v.add(SeverityError, "segment-phdr", fmt.Sprintf("segment %d (%v)", i, p.Type), "program header table is not mapped by a PT_LOAD segment")
+		}
+	}
+}
+
+// checkMapping checks that every allocated section is mapped by a PT_LOAD
+// segment at the address that corresponds to its file offset, and that no
+// section straddles the file range of a segment.
+func (v *verifier) checkMapping() {
+	f := v.f
+	hasLoad := slices.ContainsFunc(f.Segments, func(p *Segment) bool { return p.Type == elf.PT_LOAD })
+	for i, s := range f.Sections {
+		if s.Type == elf.SHT_NULL || s.Size == 0 {
+			continue
+		}
+		loc := sectionLocation(i, s)
+		if s.Type != elf.SHT_NOBITS {
+			for j, p := range f.Segments {
+				if p.Filesz == 0 || p.Type == elf.PT_GNU_RELRO {
+					continue
+				}
+				inside := s.Offset >= p.Off && s.Offset+s.Size <= p.Off+p.Filesz
+				if !inside && s.Offset < p.Off+p.Filesz && s.Offset+s.Size > p.Off {
+					v.add(SeverityWarning, "segment-straddle", loc, "straddles the boundary of segment %d (%v)", j, p.Type)
+				}
+			}
+		}
+		if !hasLoad || s.Flags&elf.SHF_ALLOC == 0 || (s.Flags&elf.SHF_TLS != 0 && s.Type == elf.SHT_NOBITS) {
+			continue
+		}
+		size := s.Size
+		if s.Type == elf.SHT_NOBITS {
+			size = 0
+		}
+		if !v.loaded(s.Offset, s.Addr, size) {
+			v.add(SeverityError, "section-unmapped", loc, "allocated section at 0x%x is not mapped by any PT_LOAD segment", s.Addr)
+		}
+	}
+}
+
+// loaded reports whether the file range of the given size at off is mapped by
+// a PT_LOAD segment at addr. A size of 0 only checks the address.
+func (v *verifier) loaded(off, addr, size uint64) bool {
+	for _, p := range v.f.Segments {
+		if p.Type != elf.PT_LOAD || addr < p.Vaddr || addr >= p.Vaddr+max(p.Memsz, 1) {
+			continue
+		}
+		if size == 0 {
+			return true
+		}
+		if off >= p.Off && off+size <= p.Off+p.Filesz && off-p.Off == addr-p.Vaddr {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify parses the ELF data and runs the structural checks of File.Verify on it.
+//
+// Parameters:
+//   - elfData: A byte slice containing the raw ELF file data.
+//
+// Returns:
+//   - The problems found, errors first, or nil if the file is sound.
+//   - An error if the ELF data cannot be parsed at all.
+func Verify(elfData []byte) ([]Problem, error) {
+	f, err := Parse(elfData)
+	if err != nil {
+		return nil, err
+	}
+	return f.Verify(), nil
+}
@@ -0,0 +1,65 @@
+package elfy
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"slices"
+	"testing"
+)
+
+func TestVerifyChecks(t *testing.T) {
+	data, f := buildFile(t, testBuilders()["x86_64/exec"])
+	le := binary.LittleEndian
+	// sh and ph return the bytes of a field of a section or program header
+	sh := func(b []byte, name string, field int) []byte {
+		return b[int(f.shoff)+f.Section(name).Index()*f.sectionHeaderSize()+field:]
+	}
+	ph := func(b []byte, i, field int) []byte {
+		return b[int(f.phoff)+i*f.progHeaderSize()+field:]
+	}
+	const (
+		shType, shFlags, shAddr, shOffset, shSize, shLink, shInfo, shAddralign = 4, 8, 16, 24, 32, 40, 44, 48
+		phOff, phVaddr, phFilesz, phMemsz                                      = 8, 16, 32, 40
+		phdr, text, data2, note, stack                                         = 0, 1, 2, 3, 4
+	)
+	tests := []struct {
+		check    string
+		severity Severity
+		corrupt  func(b []byte)
+	}{
+		{"shstrndx", SeverityError, func(b []byte) { le.PutUint32(sh(b, ".shstrtab", shType), uint32(elf.SHT_PROGBITS)) }},
+		{"section-name", SeverityError, func(b []byte) { le.PutUint32(sh(b, ".comment", 0), uint32(f.shstrtab.Size)) }},
+		{"section-bounds", SeverityError, func(b []byte) { le.PutUint64(sh(b, ".comment", shSize), uint64(len(b))) }},
+		{"section-align", SeverityError, func(b []byte) { le.PutUint64(sh(b, ".data", shAddralign), 12) }},
+		{"section-align", SeverityError, func(b []byte) { le.PutUint64(sh(b, ".data", shAddralign), 0x10000) }},
+		{"section-align", SeverityWarning, func(b []byte) { le.PutUint64(sh(b, ".comment", shAddralign), 0x10000) }},
+		{"section-link", SeverityError, func(b []byte) { le.PutUint32(sh(b, ".comment", shLink), 100) }},
+		{"section-info", SeverityError, func(b []byte) {
+			le.PutUint64(sh(b, ".comment", shFlags), uint64(elf.SHF_INFO_LINK))
+			le.PutUint32(sh(b, ".comment", shInfo), 100)
+		}},
+		{"section-overlap", SeverityError, func(b []byte) {
+			copy(sh(b, ".comment", shOffset)[:8], sh(b, ".data", shOffset)[:8])
+		}},
+		{"segment-bounds", SeverityError, func(b []byte) { le.PutUint64(ph(b, stack, phOff), uint64(len(b))+1) }},
+		{"segment-size", SeverityError, func(b []byte) { le.PutUint64(ph(b, data2, phMemsz), 1) }},
+		{"segment-align", SeverityError, func(b []byte) { le.PutUint64(ph(b, data2, phVaddr), le.Uint64(ph(b, data2, phVaddr))+8) }},
+		{"segment-order", SeverityError, func(b []byte) { le.PutUint64(ph(b, data2, phVaddr), le.Uint64(ph(b, text, phVaddr))-0x1000) }},
+		{"segment-overlap", SeverityError, func(b []byte) { le.PutUint64(ph(b, text, phMemsz), 1<<30) }},
+		{"segment-phdr", SeverityError, func(b []byte) { le.PutUint64(ph(b, phdr, phVaddr), 0x10) }},
+		{"segment-straddle", SeverityWarning, func(b []byte) { le.PutUint64(ph(b, note, phFilesz), le.Uint64(ph(b, note, phFilesz))-1) }},
+		{"section-unmapped", SeverityError, func(b []byte) { le.PutUint64(sh(b, ".data", shAddr), le.Uint64(sh(b, ".data", shAddr))+1<<20) }},
+	}
+	for _, tt := range tests {
+		b := slices.Clone(data)
+		tt.corrupt(b)
+		problems, err := Verify(b)
+		if err != nil {
+			t.Errorf("%s: %v", tt.check, err)
+			continue
+		}
+		if !slices.ContainsFunc(problems, func(p Problem) bool { return p.Check == tt.check && p.Severity == tt.severity }) {
+			t.Errorf("%s: no %v reported, got %v", tt.check, tt.severity, problems)
+		}
+	}
+}
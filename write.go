@@ -0,0 +1,299 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Bytes serializes the File, including all pending edits, into a new byte slice.
+// Sections are placed according to the file's Layout strategy; see PlanLayout.
+//
+// Returns:
+//   - A byte slice containing the modified ELF file data.
+//   - An error if the file cannot be serialized.
+func (f *File) Bytes() ([]byte, error) {
+	plan, err := f.PlanLayout()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, plan.Size)
+	copy(out, f.raw[:plan.keep])
+	for _, e := range plan.free {
+		clear(out[e.off:e.end])
+	}
+	for _, s := range f.Sections {
+		if s.inPlace {
+			clear(out[s.Offset : s.Offset+s.origSize])
+			copy(out[s.Offset:], s.data)
+		}
+	}
+	for s, off := range plan.offsets {
+		if s.Type == elf.SHT_NOBITS {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, err
+		}
+		copy(out[off:], data)
+	}
+
+	var shdrBuf bytes.Buffer
+	for i, s := range f.Sections {
+		off, moved := plan.offsets[s]
+		if !moved {
+			off = s.Offset
+		}
+		if i == 0 {
+			s = f.extendedNull(s)
+		}
+		if err := f.writeSectionHeader(&shdrBuf, s, off); err != nil {
+			return nil, err
+		}
+	}
+	copy(out[plan.SectionHeaderOffset:], shdrBuf.Bytes())
+
+	if plan.ProgramHeaderOffset == f.phoff && f.phnum > 0 && f.phoff < plan.keep {
+		// Do not leave stale entries behind when segments were removed
+		clear(out[f.phoff:min(f.phoff+uint64(f.phnum)*uint64(f.phentsize), plan.keep)])
+	}
+	var phdrBuf bytes.Buffer
+	for _, p := range plan.progs {
+		if err := f.writeProgHeader(&phdrBuf, p); err != nil {
+			return nil, err
+		}
+	}
+	copy(out[plan.ProgramHeaderOffset:], phdrBuf.Bytes())
+
+	var hdrBuf bytes.Buffer
+	if err := f.writeHeader(&hdrBuf, plan); err != nil {
+		return nil, err
+	}
+	copy(out, hdrBuf.Bytes())
+	copy(out[plan.Size-uint64(len(f.overlay)):], f.overlay)
+	return out, nil
+}
+
+// WriteTo serializes the File and writes it to w.
+// It implements io.WriterTo.
+//
+// Parameters:
+//   - w: The destination writer.
+//
+// Returns:
+//   - The number of bytes written.
+//   - An error if serialization or writing fails.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	out, err := f.Bytes()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(out)
+	if err != nil {
+		return int64(n), fmt.Errorf("error writing output: %w", err)
+	}
+	return int64(n), nil
+}
+
+// syncNames makes sure every section name is present in .shstrtab, appending
+// missing names and marking .shstrtab as changed when it grows.
+func (f *File) syncNames() error {
+	if f.shstrtab == nil {
+		if len(f.Sections) > 0 {
+			return formatErrorf(0, "e_shstrndx", "no section header string table")
+		}
+		return nil
+	}
+	shstrtabData, err := f.shstrtab.Data()
+	if err != nil {
+		return fmt.Errorf("error reading .shstrtab: %w", err)
+	}
+	grown := false
+	for _, s := range f.Sections {
+		if getString(shstrtabData, int(s.nameOff)) == s.Name {
+			continue
+		}
+		nameOffset := findStringOffset(shstrtabData, s.Name)
+		if nameOffset == -1 {
+			nameOffset = len(shstrtabData)
+			shstrtabData = append(shstrtabData, s.Name...)
+			shstrtabData = append(shstrtabData, 0)
+			grown = true
+		}
+		s.nameOff = uint32(nameOffset)
+	}
+	if grown {
+		f.shstrtab.SetData(shstrtabData)
+	}
+	return nil
+}
+
+// writeHeader encodes the class-specific ELF file header to w, pointing it at
+// the program and section header tables placed by plan.
+func (f *File) writeHeader(w io.Writer, plan *LayoutPlan) error {
+	ident := f.ident
+	ident[elf.EI_CLASS] = byte(f.Class)
+	ident[elf.EI_DATA] = byte(f.Data)
+	ident[elf.EI_VERSION] = byte(f.Version)
+	ident[elf.EI_OSABI] = byte(f.OSABI)
+	ident[elf.EI_ABIVERSION] = f.ABIVersion
+
+	shnum, shstrndx := uint16(len(f.Sections)), uint16(f.shstrndx())
+	if len(f.Sections) >= int(elf.SHN_LORESERVE) {
+		shnum = 0
+	}
+	if f.shstrndx() >= int(elf.SHN_LORESERVE) {
+		shstrndx = uint16(elf.SHN_XINDEX)
+	}
+	shentsize := f.shentsize
+	if len(f.Sections) > 0 {
+		shentsize = uint16(f.sectionHeaderSize())
+	}
+	phnum, phentsize := uint16(len(plan.progs)), f.phentsize
+	if phnum > 0 {
+		phentsize = uint16(f.progHeaderSize())
+	}
+
+	var hdr any
+	if f.Class == elf.ELFCLASS64 {
+		hdr = &elf.Header64{
+			Ident:     ident,
+			Type:      uint16(f.Type),
+			Machine:   uint16(f.Machine),
+			Version:   uint32(f.Version),
+			Entry:     f.Entry,
+			Phoff:     plan.ProgramHeaderOffset,
+			Shoff:     plan.SectionHeaderOffset,
+			Flags:     f.flags,
+			Ehsize:    f.ehsize,
+			Phentsize: phentsize,
+			Phnum:     phnum,
+			Shentsize: shentsize,
+			Shnum:     shnum,
+			Shstrndx:  shstrndx,
+		}
+	} else {
+		hdr = &elf.Header32{
+			Ident:     ident,
+			Type:      uint16(f.Type),
+			Machine:   uint16(f.Machine),
+			Version:   uint32(f.Version),
+			Entry:     uint32(f.Entry),
+			Phoff:     uint32(plan.ProgramHeaderOffset),
+			Shoff:     uint32(plan.SectionHeaderOffset),
+			Flags:     f.flags,
+			Ehsize:    f.ehsize,
+			Phentsize: phentsize,
+			Phnum:     phnum,
+			Shentsize: shentsize,
+			Shnum:     shnum,
+			Shstrndx:  shstrndx,
+		}
+	}
+	if err := binary.Write(w, f.ByteOrder, hdr); err != nil {
+		return fmt.Errorf("error writing ELF header: %w", err)
+	}
+	return nil
+}
+
+// shstrndx returns the index of the section header string table, or 0 if there is none.
+func (f *File) shstrndx() int {
+	if f.shstrtab == nil {
+		return 0
+	}
+	return f.shstrtab.Index()
+}
+
+// extendedNull returns the header to write for section 0, carrying the section
+// count and .shstrtab index when they do not fit into the ELF header.
+func (f *File) extendedNull(null *Section) *Section {
+	ext := *null
+	if len(f.Sections) >= int(elf.SHN_LORESERVE) {
+		ext.Size = uint64(len(f.Sections))
+	}
+	if f.shstrndx() >= int(elf.SHN_LORESERVE) {
+		ext.Link = uint32(f.shstrndx())
+	}
+	return &ext
+}
+
+// writeSectionHeader encodes a single class-specific section header to w,
+// recording off as the section's file offset.
+func (f *File) writeSectionHeader(w io.Writer, s *Section, off uint64) error {
+	var sh any
+	if f.Class == elf.ELFCLASS64 {
+		sh = &elf.Section64{
+			Name:      s.nameOff,
+			Type:      uint32(s.Type),
+			Flags:     uint64(s.Flags),
+			Addr:      s.Addr,
+			Off:       off,
+			Size:      s.Size,
+			Link:      s.Link,
+			Info:      s.Info,
+			Addralign: s.Addralign,
+			Entsize:   s.Entsize,
+		}
+	} else {
+		sh = &elf.Section32{
+			Name:      s.nameOff,
+			Type:      uint32(s.Type),
+			Flags:     uint32(s.Flags),
+			Addr:      uint32(s.Addr),
+			Off:       uint32(off),
+			Size:      uint32(s.Size),
+			Link:      s.Link,
+			Info:      s.Info,
+			Addralign: uint32(s.Addralign),
+			Entsize:   uint32(s.Entsize),
+		}
+	}
+	if err := binary.Write(w, f.ByteOrder, sh); err != nil {
+		return fmt.Errorf("error writing section header: %w", err)
+	}
+	return nil
+}
+
+// writeProgHeader encodes a single class-specific program header to w.
+func (f *File) writeProgHeader(w io.Writer, p elf.ProgHeader) error {
+	var ph any
+	if f.Class == elf.ELFCLASS64 {
+		ph = &elf.Prog64{
+			Type:   uint32(p.Type),
+			Flags:  uint32(p.Flags),
+			Off:    p.Off,
+			Vaddr:  p.Vaddr,
+			Paddr:  p.Paddr,
+			Filesz: p.Filesz,
+			Memsz:  p.Memsz,
+			Align:  p.Align,
+		}
+	} else {
+		ph = &elf.Prog32{
+			Type:   uint32(p.Type),
+			Off:    uint32(p.Off),
+			Vaddr:  uint32(p.Vaddr),
+			Paddr:  uint32(p.Paddr),
+			Filesz: uint32(p.Filesz),
+			Memsz:  uint32(p.Memsz),
+			Flags:  uint32(p.Flags),
+			Align:  uint32(p.Align),
+		}
+	}
+	if err := binary.Write(w, f.ByteOrder, ph); err != nil {
+		return fmt.Errorf("error writing program header: %w", err)
+	}
+	return nil
+}
+
+// alignUp rounds offset up to the next multiple of alignment.
+func alignUp(offset, alignment uint64) uint64 {
+	if alignment <= 1 || offset%alignment == 0 {
+		return offset
+	}
+	return offset + alignment - offset%alignment
+}
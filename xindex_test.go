@@ -0,0 +1,93 @@
+package elfy
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func TestExtendedSectionNumbering(t *testing.T) {
+	const count = 70000
+	b := NewBuilder(elf.ELFCLASS64, elf.ELFDATA2LSB, elf.ET_REL, elf.EM_X86_64)
+	for i := range count {
+		b.Sections = append(b.Sections, BuildSection{Name: fmt.Sprintf(".s%d", i), Type: elf.SHT_PROGBITS, Addralign: 1})
+	}
+	// The Builder cannot define symbols above SHN_LORESERVE, so last is added
+	// afterwards and stored in the SHT_SYMTAB_SHNDX table
+	b.Sections = append(b.Sections, BuildSection{Name: ".symtab_shndx", Type: elf.SHT_SYMTAB_SHNDX, Addralign: 4, Entsize: 4, Link: ".symtab", Data: make([]byte, 8)})
+	b.Symbols = []BuildSymbol{{Name: "sym", Section: ".s1", Bind: elf.STB_GLOBAL}}
+	built, f := buildFile(t, b)
+	if out, err := f.Bytes(); err != nil || !bytes.Equal(out, built) {
+		t.Fatalf("unedited file does not round-trip: %v", err)
+	}
+	last := fmt.Sprintf(".s%d", count-1)
+	if err := f.AddSymbol(Symbol{Name: "last", Section: last, Bind: elf.STB_GLOBAL}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f = parseFile(t, data)
+	if err := f.RemoveSection(".s0"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name  string
+		data  []byte
+		num   int
+		shndx int // Index of .s1
+	}{{"added", data, len(f.Sections) + 1, 2}, {"removed", out, len(f.Sections), 1}} {
+		t.Run(tt.name, func(t *testing.T) {
+			// e_shnum and e_shstrndx defer to sh_size and sh_link of section 0
+			shoff := binary.LittleEndian.Uint64(tt.data[0x28:])
+			if shnum := binary.LittleEndian.Uint16(tt.data[0x3c:]); shnum != 0 {
+				t.Errorf("e_shnum = %d, want 0", shnum)
+			}
+			if shstrndx := binary.LittleEndian.Uint16(tt.data[0x3e:]); shstrndx != uint16(elf.SHN_XINDEX) {
+				t.Errorf("e_shstrndx = 0x%x, want SHN_XINDEX", shstrndx)
+			}
+			if size := binary.LittleEndian.Uint64(tt.data[shoff+0x20:]); size != uint64(tt.num) {
+				t.Errorf("sh_size of section 0 = %d, want %d", size, tt.num)
+			}
+			if link := binary.LittleEndian.Uint32(tt.data[shoff+0x28:]); link != uint32(tt.num-1) {
+				t.Errorf("sh_link of section 0 = %d, want %d", link, tt.num-1)
+			}
+
+			ef, err := elf.NewFile(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ef.Sections) != tt.num || ef.Sections[tt.num-1].Name != ".shstrtab" {
+				t.Errorf("debug/elf reads %d sections, want %d ending with .shstrtab", len(ef.Sections), tt.num)
+			}
+			g := parseFile(t, tt.data)
+			checkVerify(t, g)
+			syms, err := g.Symbols()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(syms) != 2 || syms[0].Section != ".s1" || int(syms[0].Shndx) != tt.shndx {
+				t.Fatalf("symbols = %+v, want sym in .s1 at index %d", syms, tt.shndx)
+			}
+			// st_shndx is SHN_XINDEX, the index is in .symtab_shndx
+			if s := g.Section(last); syms[1].Section != last || int(syms[1].Shndx) != s.Index() || s.Index() < int(elf.SHN_LORESERVE) {
+				t.Errorf("symbol last = %+v, want section %s at index %d", syms[1], last, s.Index())
+			}
+			esyms, err := ef.Symbols()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if esyms[1].Section != elf.SHN_XINDEX {
+				t.Errorf("st_shndx of last = 0x%x, want SHN_XINDEX", uint16(esyms[1].Section))
+			}
+		})
+	}
+}